@@ -0,0 +1,190 @@
+package baseline
+
+// Named benchmark results kept on disk, to compare later runs against.
+// A baseline keeps every latency, not only the percentiles, so changes
+// can be tested for significance.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"dns-helper/internal/bench"
+)
+
+// Dir holds one <name>.json per baseline, replaced in tests
+var Dir = defaultDir()
+
+func defaultDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "dns-helper", "baselines")
+	}
+	return filepath.Join(dir, "dns-helper", "baselines")
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Profile is the result of one profile
+type Profile struct {
+	Latencies []time.Duration `json:"latencies_ns"`
+	Successes int             `json:"successes"`
+	Total     int             `json:"total"`
+}
+
+// Result converts back to a benchmark result
+func (p Profile) Result() bench.Result {
+	return bench.Result{Latencies: p.Latencies, Successes: p.Successes, Total: p.Total}
+}
+
+// Baseline is a saved benchmark run
+type Baseline struct {
+	Name     string             `json:"name"`
+	Time     time.Time          `json:"time"`
+	Host     string             `json:"host"`
+	Domains  []string           `json:"domains"`
+	Runs     int                `json:"runs"`
+	Timeout  time.Duration      `json:"timeout_ns"`
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// New makes a baseline from benchmark results
+func New(name string, results map[string]bench.Result, domains []string, runs int, timeout time.Duration) Baseline {
+	b := Baseline{Name: name, Time: time.Now().UTC(), Domains: domains, Runs: runs, Timeout: timeout, Profiles: map[string]Profile{}}
+	b.Host, _ = os.Hostname()
+	for name, r := range results {
+		b.Profiles[name] = Profile{Latencies: r.Latencies, Successes: r.Successes, Total: r.Total}
+	}
+	return b
+}
+
+func path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid baseline name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return filepath.Join(Dir, name+".json"), nil
+}
+
+// Save writes b, replacing a baseline of the same name
+func Save(b Baseline) error {
+	p, err := path(b.Name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, append(data, '\n'), 0644)
+}
+
+// Load reads the baseline called name
+func Load(name string) (Baseline, error) {
+	var b Baseline
+	p, err := path(name)
+	if err != nil {
+		return b, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, fmt.Errorf("no baseline named %s (save one with --save-baseline %s)", name, name)
+	}
+	if err != nil {
+		return b, err
+	}
+	if err := json.Unmarshal(data, &b); err != nil {
+		return b, fmt.Errorf("%s: %v", p, err)
+	}
+	return b, nil
+}
+
+// Thresholds decide when a change is a regression. Latency increases are
+// in percent of the baseline, the success rate drop in percentage points.
+// A change only counts when it is also significant at Alpha.
+type Thresholds struct {
+	P50, P90, Success float64
+	Alpha             float64
+}
+
+// Delta compares one profile with its baseline
+type Delta struct {
+	Profile              string
+	Base, Current        bench.Result
+	LatencyP, SuccessP   float64 // p-values of the latency and success rate changes
+	BaseRate, Rate       float64 // success rates in percent
+	P50Change, P90Change float64 // percent of the baseline; 0 without a baseline value
+	Missing              bool    // the profile is not in the baseline
+}
+
+// Compare computes the deltas for the profiles of current in names, in
+// that order
+func Compare(base Baseline, current map[string]bench.Result, names []string) []Delta {
+	var out []Delta
+	for _, name := range names {
+		cur := current[name]
+		d := Delta{Profile: name, Current: cur, Rate: rate(cur), LatencyP: 1, SuccessP: 1}
+		bp, ok := base.Profiles[name]
+		if !ok {
+			d.Missing = true
+			out = append(out, d)
+			continue
+		}
+		d.Base = bp.Result()
+		d.BaseRate = rate(d.Base)
+		d.P50Change = change(d.Base.P50MS(), cur.P50MS())
+		d.P90Change = change(d.Base.P90MS(), cur.P90MS())
+		d.LatencyP = bench.MannWhitney(millis(d.Base.Latencies), millis(cur.Latencies))
+		d.SuccessP = bench.ProportionTest(d.Base.Successes, d.Base.Total, cur.Successes, cur.Total)
+		out = append(out, d)
+	}
+	return out
+}
+
+// Regressions lists the thresholds d exceeds with a significant change
+func (d Delta) Regressions(th Thresholds) []string {
+	if d.Missing {
+		return nil
+	}
+	var out []string
+	if d.LatencyP < th.Alpha {
+		if d.P50Change > th.P50 {
+			out = append(out, fmt.Sprintf("p50 %+.1f%% (threshold %.0f%%)", d.P50Change, th.P50))
+		}
+		if d.P90Change > th.P90 {
+			out = append(out, fmt.Sprintf("p90 %+.1f%% (threshold %.0f%%)", d.P90Change, th.P90))
+		}
+	}
+	if drop := d.BaseRate - d.Rate; d.SuccessP < th.Alpha && drop > th.Success {
+		out = append(out, fmt.Sprintf("success -%.1f points (threshold %.1f)", drop, th.Success))
+	}
+	return out
+}
+
+func rate(r bench.Result) float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return 100 * float64(r.Successes) / float64(r.Total)
+}
+
+func change(base, cur float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return 100 * (cur - base) / base
+}
+
+func millis(durs []time.Duration) []float64 {
+	out := make([]float64, len(durs))
+	for i, d := range durs {
+		out[i] = float64(d) / float64(time.Millisecond)
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package baseline
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"dns-helper/internal/bench"
+)
+
+func useDir(t *testing.T) {
+	orig := Dir
+	Dir = t.TempDir()
+	t.Cleanup(func() { Dir = orig })
+}
+
+func result(base time.Duration, n, failures int) bench.Result {
+	r := bench.Result{Total: n}
+	for i := 0; i < n-failures; i++ {
+		r.Latencies = append(r.Latencies, base+time.Duration(i%5)*time.Millisecond)
+		r.Successes++
+	}
+	return r
+}
+
+var th = Thresholds{P50: 20, P90: 30, Success: 5, Alpha: 0.05}
+
+func TestSaveLoad(t *testing.T) {
+	useDir(t)
+	b := New("office", map[string]bench.Result{"cloudflare": result(10*time.Millisecond, 15, 1)}, []string{"example.com"}, 5, time.Second)
+	if err := Save(b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load("office")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := got.Profiles["cloudflare"]
+	if got.Runs != 5 || got.Timeout != time.Second || len(p.Latencies) != 14 || p.Successes != 14 || p.Total != 15 {
+		t.Errorf("Unexpected baseline: %+v", got)
+	}
+	if _, err := Load("home"); err == nil || !strings.Contains(err.Error(), "--save-baseline home") {
+		t.Errorf("Expected a hint for a missing baseline, got %v", err)
+	}
+	if err := Save(Baseline{Name: "../escape"}); err == nil {
+		t.Error("Names must not leave the directory")
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	base := New("office", map[string]bench.Result{
+		"slower":   result(10*time.Millisecond, 15, 0),
+		"same":     result(10*time.Millisecond, 15, 0),
+		"failing":  result(10*time.Millisecond, 60, 0),
+		"onefluke": result(10*time.Millisecond, 15, 0),
+	}, nil, 5, time.Second)
+	current := map[string]bench.Result{
+		"slower":   result(20*time.Millisecond, 15, 0),
+		"same":     result(10*time.Millisecond, 15, 0),
+		"failing":  result(10*time.Millisecond, 60, 12),
+		"onefluke": result(10*time.Millisecond, 15, 1),
+		"new":      result(10*time.Millisecond, 15, 0),
+	}
+	deltas := Compare(base, current, []string{"failing", "new", "onefluke", "same", "slower"})
+	got := map[string][]string{}
+	for _, d := range deltas {
+		got[d.Profile] = d.Regressions(th)
+	}
+	if len(got["slower"]) != 2 || !strings.HasPrefix(got["slower"][0], "p50 +") {
+		t.Errorf("Expected p50 and p90 regressions, got %q", got["slower"])
+	}
+	if len(got["failing"]) != 1 || !strings.HasPrefix(got["failing"][0], "success -20.0 points") {
+		t.Errorf("Expected a success rate regression, got %q", got["failing"])
+	}
+	for _, name := range []string{"same", "onefluke", "new"} {
+		if len(got[name]) != 0 {
+			t.Errorf("%s: unexpected regressions %q", name, got[name])
+		}
+	}
+	if !deltas[1].Missing {
+		t.Error("A profile without baseline must be marked missing")
+	}
+}
@@ -2,6 +2,7 @@ package bench
 
 import (
 	"context"
+	"errors"
 	"net"
 	"sort"
 	"time"
@@ -12,6 +13,7 @@ type Result struct {
 	Latencies []time.Duration
 	Successes int
 	Total     int
+	Samples   []Sample // every query in order, failures included
 }
 
 func (r Result) AvgMS() float64 {
@@ -37,6 +39,9 @@ func percentile(durs []time.Duration, p float64) float64 {
 	return float64(cp[idx].Milliseconds())
 }
 
+// resolve is swapped out in tests
+var resolve = resolveOnce
+
 // Run: profileName -> IP:port list
 func Run(targets map[string][]string, domains []string, runs int, timeout time.Duration) map[string]Result {
 	out := make(map[string]Result)
@@ -45,12 +50,18 @@ func Run(targets map[string][]string, domains []string, runs int, timeout time.D
 		for _, domain := range domains {
 			for i := 0; i < runs; i++ {
 				start := time.Now()
-				ok := resolveOnce(servers, domain, timeout)
+				err := resolve(servers, domain, timeout)
+				elapsed := time.Since(start)
 				res.Total++
-				if ok {
+				s := Sample{Time: start.UTC(), Profile: name, Domain: domain, Class: classifyLookup(err)}
+				if err == nil {
 					res.Successes++
-					res.Latencies = append(res.Latencies, time.Since(start))
+					res.Latencies = append(res.Latencies, elapsed)
+				}
+				if s.Answered() {
+					s.Latency = elapsed
 				}
+				res.Samples = append(res.Samples, s)
 			}
 		}
 		out[name] = res
@@ -58,7 +69,26 @@ func Run(targets map[string][]string, domains []string, runs int, timeout time.D
 	return out
 }
 
-func resolveOnce(servers []string, domain string, timeout time.Duration) bool {
+// classifyLookup sorts a resolver error into the classes of Classify
+func classifyLookup(err error) string {
+	if err == nil {
+		return ClassSuccess
+	}
+	var de *net.DNSError
+	if errors.As(err, &de) {
+		switch {
+		case de.IsTimeout:
+			return ClassTimeout
+		case de.IsNotFound:
+			return ClassNXDomain
+		case de.Err == "server misbehaving":
+			return ClassServFail
+		}
+	}
+	return Classify(Response{}, err)
+}
+
+func resolveOnce(servers []string, domain string, timeout time.Duration) error {
 	// custom resolver: UDP 53
 	dialer := func(ctx context.Context, network, address string) (net.Conn, error) {
 		// try servers in order
@@ -80,5 +110,5 @@ func resolveOnce(servers []string, domain string, timeout time.Duration) bool {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	_, err := r.LookupHost(ctx, domain)
-	return err == nil
+	return err
 }
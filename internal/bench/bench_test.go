@@ -1,6 +1,7 @@
 package bench
 
 import (
+	"net"
 	"testing"
 	"time"
 )
@@ -161,3 +162,31 @@ func stripPortsHelper(server string) string {
 	}
 	return server
 }
+
+func TestRunRecordsSamples(t *testing.T) {
+	orig := resolve
+	defer func() { resolve = orig }()
+	resolve = func(servers []string, domain string, timeout time.Duration) error {
+		switch domain {
+		case "missing.example":
+			return &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+		case "slow.example":
+			return &net.DNSError{Err: "i/o timeout", Name: domain, IsTimeout: true}
+		}
+		return nil
+	}
+
+	r := Run(map[string][]string{"test": {"192.0.2.1:53"}}, []string{"example.com", "missing.example", "slow.example"}, 2, time.Second)["test"]
+	if r.Total != 6 || r.Successes != 2 || len(r.Latencies) != 2 || len(r.Samples) != 6 {
+		t.Fatalf("Unexpected result: total=%d successes=%d latencies=%d samples=%d", r.Total, r.Successes, len(r.Latencies), len(r.Samples))
+	}
+	expected := []string{ClassSuccess, ClassSuccess, ClassNXDomain, ClassNXDomain, ClassTimeout, ClassTimeout}
+	for i, s := range r.Samples {
+		if s.Class != expected[i] || s.Profile != "test" {
+			t.Errorf("Sample %d: %+v, expected class %s", i, s, expected[i])
+		}
+		if s.Class == ClassTimeout && s.Latency != 0 {
+			t.Errorf("A timeout has no latency: %+v", s)
+		}
+	}
+}
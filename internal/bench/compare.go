@@ -0,0 +1,169 @@
+package bench
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// exchange is swapped out in tests
+var exchange = Exchange
+
+// Member is one server's view of a compared name
+type Member struct {
+	Profile string
+	Server  string
+	TTL     uint32 // lowest TTL in the answer set
+	RTT     time.Duration
+}
+
+// Group is a set of servers that returned an identical answer set
+type Group struct {
+	RCode    dnsmessage.RCode
+	Answers  []string // sorted record values, TTLs excluded
+	Members  []Member
+	Filtered bool // blocked (NXDOMAIN or sinkhole) while other groups resolved
+}
+
+// Comparison holds the grouped answers for one name
+type Comparison struct {
+	Name   string
+	Type   dnsmessage.Type
+	Groups []Group
+	Errors map[string]error // "profile server" -> query error
+}
+
+// MinTTL returns the lowest TTL reported by the group's members
+func (g Group) MinTTL() uint32 {
+	var min uint32
+	for i, m := range g.Members {
+		if i == 0 || m.TTL < min {
+			min = m.TTL
+		}
+	}
+	return min
+}
+
+// MaxTTL returns the highest TTL reported by the group's members
+func (g Group) MaxTTL() uint32 {
+	var max uint32
+	for _, m := range g.Members {
+		if m.TTL > max {
+			max = m.TTL
+		}
+	}
+	return max
+}
+
+// Compare queries name on every server of every profile and groups
+// servers by identical answer set.
+func Compare(targets map[string][]string, name string, qtype dnsmessage.Type, timeout time.Duration) Comparison {
+	type result struct {
+		member Member
+		resp   Response
+		err    error
+	}
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results []result
+	)
+	for profile, servers := range targets {
+		for _, s := range servers {
+			wg.Add(1)
+			go func(profile, server string) {
+				defer wg.Done()
+				resp, err := exchange(server, Question{Name: name, Type: qtype}, timeout)
+				mu.Lock()
+				results = append(results, result{Member{Profile: profile, Server: server, RTT: resp.RTT}, resp, err})
+				mu.Unlock()
+			}(profile, s)
+		}
+	}
+	wg.Wait()
+
+	cmp := Comparison{Name: name, Type: qtype, Errors: map[string]error{}}
+	byKey := map[string]*Group{}
+	var keys []string
+	for _, r := range results {
+		if r.err != nil {
+			cmp.Errors[r.member.Profile+" "+r.member.Server] = r.err
+			continue
+		}
+		answers, ttl := Records(r.resp, qtype)
+		r.member.TTL = ttl
+		key := RCodeName(r.resp.RCode) + "|" + strings.Join(answers, ",")
+		g, ok := byKey[key]
+		if !ok {
+			g = &Group{RCode: r.resp.RCode, Answers: answers}
+			byKey[key] = g
+			keys = append(keys, key)
+		}
+		g.Members = append(g.Members, r.member)
+	}
+	for _, k := range keys {
+		g := byKey[k]
+		sort.Slice(g.Members, func(i, j int) bool {
+			if g.Members[i].Profile != g.Members[j].Profile {
+				return g.Members[i].Profile < g.Members[j].Profile
+			}
+			return g.Members[i].Server < g.Members[j].Server
+		})
+		cmp.Groups = append(cmp.Groups, *g)
+	}
+	markFiltered(cmp.Groups)
+	// largest group first: that is the "consensus" answer; groups of the
+	// same size are ordered by their members, not by which answered first
+	sort.Slice(cmp.Groups, func(i, j int) bool {
+		a, b := cmp.Groups[i].Members, cmp.Groups[j].Members
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return memberNames(a) < memberNames(b)
+	})
+	return cmp
+}
+
+// memberNames joins the sorted members as "profile server" lines
+func memberNames(members []Member) string {
+	var b strings.Builder
+	for _, m := range members {
+		b.WriteString(m.Profile + " " + m.Server + "\n")
+	}
+	return b.String()
+}
+
+// markFiltered flags blocked groups, but only when some other group got a
+// real answer; a name that is NXDOMAIN everywhere is not being filtered.
+func markFiltered(groups []Group) {
+	resolved := false
+	for _, g := range groups {
+		if !blocked(g) && len(g.Answers) > 0 {
+			resolved = true
+		}
+	}
+	if !resolved {
+		return
+	}
+	for i := range groups {
+		groups[i].Filtered = blocked(groups[i])
+	}
+}
+
+func blocked(g Group) bool {
+	if g.RCode == dnsmessage.RCodeNameError {
+		return true
+	}
+	if len(g.Answers) == 0 {
+		return false
+	}
+	for _, a := range g.Answers {
+		if a != "0.0.0.0" && a != "::" {
+			return false
+		}
+	}
+	return true
+}
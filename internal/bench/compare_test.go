@@ -0,0 +1,134 @@
+package bench
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeExchange answers from a fixed server -> response table
+func fakeExchange(t *testing.T, table map[string]Response) func() {
+	orig := exchange
+	exchange = func(server string, q Question, timeout time.Duration) (Response, error) {
+		resp, ok := table[server]
+		if !ok {
+			t.Errorf("unexpected query to %s", server)
+			return Response{}, errors.New("no fake response")
+		}
+		resp.Server = server
+		return resp, nil
+	}
+	return func() { exchange = orig }
+}
+
+func aRecord(ip string, ttl uint32) Record {
+	return Record{Name: "example.com.", Type: dnsmessage.TypeA, TTL: ttl, Value: ip}
+}
+
+func TestCompareGroupsIdenticalAnswers(t *testing.T) {
+	defer fakeExchange(t, map[string]Response{
+		"1.1.1.1:53": {Answers: []Record{aRecord("192.0.2.1", 300), aRecord("192.0.2.2", 300)}},
+		"8.8.8.8:53": {Answers: []Record{aRecord("192.0.2.2", 120), aRecord("192.0.2.1", 120)}},
+		"9.9.9.9:53": {Answers: []Record{aRecord("198.51.100.7", 60)}},
+	})()
+
+	targets := map[string][]string{
+		"cloudflare": {"1.1.1.1:53"},
+		"google":     {"8.8.8.8:53"},
+		"quad9":      {"9.9.9.9:53"},
+	}
+	cmp := Compare(targets, "example.com", dnsmessage.TypeA, time.Second)
+
+	if len(cmp.Groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(cmp.Groups))
+	}
+	g := cmp.Groups[0]
+	if len(g.Members) != 2 {
+		t.Fatalf("Expected largest group to have 2 members, got %d", len(g.Members))
+	}
+	if g.MinTTL() != 120 || g.MaxTTL() != 300 {
+		t.Errorf("Expected TTL range 120..300, got %d..%d", g.MinTTL(), g.MaxTTL())
+	}
+	if g.Filtered || cmp.Groups[1].Filtered {
+		t.Error("Expected no group to be marked filtered")
+	}
+}
+
+func TestCompareFlagsFiltering(t *testing.T) {
+	defer fakeExchange(t, map[string]Response{
+		"1.1.1.1:53": {Answers: []Record{aRecord("192.0.2.1", 300)}},
+		"9.9.9.9:53": {RCode: dnsmessage.RCodeNameError},
+		"8.8.8.8:53": {Answers: []Record{aRecord("0.0.0.0", 300)}},
+	})()
+
+	targets := map[string][]string{
+		"cloudflare": {"1.1.1.1:53"},
+		"quad9":      {"9.9.9.9:53"},
+		"sinkhole":   {"8.8.8.8:53"},
+	}
+	cmp := Compare(targets, "malware.example", dnsmessage.TypeA, time.Second)
+
+	filtered := 0
+	for _, g := range cmp.Groups {
+		if g.Filtered {
+			filtered++
+		}
+	}
+	if filtered != 2 {
+		t.Errorf("Expected NXDOMAIN and 0.0.0.0 groups to be filtered, got %d filtered groups", filtered)
+	}
+}
+
+func TestCompareNXDOMAINEverywhereIsNotFiltered(t *testing.T) {
+	defer fakeExchange(t, map[string]Response{
+		"1.1.1.1:53": {RCode: dnsmessage.RCodeNameError},
+		"9.9.9.9:53": {RCode: dnsmessage.RCodeNameError},
+	})()
+
+	targets := map[string][]string{
+		"cloudflare": {"1.1.1.1:53"},
+		"quad9":      {"9.9.9.9:53"},
+	}
+	cmp := Compare(targets, "nonexistent.example", dnsmessage.TypeA, time.Second)
+
+	if len(cmp.Groups) != 1 || cmp.Groups[0].Filtered {
+		t.Errorf("Expected a single unfiltered group, got %+v", cmp.Groups)
+	}
+}
+
+func TestParseType(t *testing.T) {
+	if typ, err := ParseType("aaaa"); err != nil || typ != dnsmessage.TypeAAAA {
+		t.Errorf("ParseType(aaaa) = %v, %v", typ, err)
+	}
+	if _, err := ParseType("bogus"); err == nil {
+		t.Error("Expected error for unknown record type")
+	}
+}
+
+func TestCompareOrdersEqualGroupsByProfile(t *testing.T) {
+	defer fakeExchange(t, map[string]Response{
+		"1.1.1.1:53": {Answers: []Record{aRecord("192.0.2.1", 300)}},
+		"8.8.8.8:53": {Answers: []Record{aRecord("192.0.2.2", 300)}},
+		"9.9.9.9:53": {Answers: []Record{aRecord("192.0.2.3", 300)}},
+	})()
+
+	targets := map[string][]string{
+		"quad9":      {"9.9.9.9:53"},
+		"google":     {"8.8.8.8:53"},
+		"cloudflare": {"1.1.1.1:53"},
+	}
+	// the queries run concurrently, so repeat to catch an unstable order
+	for i := 0; i < 20; i++ {
+		cmp := Compare(targets, "example.com", dnsmessage.TypeA, time.Second)
+		var got []string
+		for _, g := range cmp.Groups {
+			got = append(got, g.Members[0].Profile)
+		}
+		if strings.Join(got, ",") != "cloudflare,google,quad9" {
+			t.Fatalf("Expected groups ordered by profile, got %v", got)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package bench
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Question describes a single DNS query sent by Exchange
+type Question struct {
+	Name   string
+	Type   dnsmessage.Type
+	Class  dnsmessage.Class // zero means IN
+	DNSSEC bool             // set the EDNS0 DO bit
+	TCP    bool             // query over TCP instead of UDP
+}
+
+// Record is a single answer record in presentation form
+type Record struct {
+	Name  string
+	Type  dnsmessage.Type
+	TTL   uint32
+	Value string
+}
+
+// Response is the parsed reply to a Question
+type Response struct {
+	Server  string
+	RCode   dnsmessage.RCode
+	AD      bool
+	Answers []Record
+	RTT     time.Duration
+}
+
+// TypeRRSIG is not defined by dnsmessage; its records are rendered as
+// "covered algorithm labels ttl expiration inception keytag"
+const TypeRRSIG dnsmessage.Type = 46
+
+// SigTimeLayout is the time layout of RRSIG expiration and inception fields
+const SigTimeLayout = "20060102150405"
+
+var qtypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"PTR":   dnsmessage.TypePTR,
+	"SOA":   dnsmessage.TypeSOA,
+	"SRV":   dnsmessage.TypeSRV,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+// ParseType converts a record type name such as "AAAA" to its wire value
+func ParseType(s string) (dnsmessage.Type, error) {
+	if t, ok := qtypes[strings.ToUpper(s)]; ok {
+		return t, nil
+	}
+	return 0, fmt.Errorf("unsupported record type: %s", s)
+}
+
+// TypeName returns the short name of a record type ("A", "AAAA", ...)
+func TypeName(t dnsmessage.Type) string {
+	return strings.TrimPrefix(t.String(), "Type")
+}
+
+// RCodeName returns the short name of a response code ("NXDOMAIN", ...)
+func RCodeName(rc dnsmessage.RCode) string {
+	switch rc {
+	case dnsmessage.RCodeSuccess:
+		return "NOERROR"
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	}
+	return rc.String()
+}
+
+// withPort appends the default DNS port when the address has none
+func withPort(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+}
+
+// Exchange sends q to a single server and parses the reply.
+// A truncated UDP reply is retried over TCP.
+func Exchange(server string, q Question, timeout time.Duration) (Response, error) {
+	name, err := dnsmessage.NewName(dnsName(q.Name))
+	if err != nil {
+		return Response{}, fmt.Errorf("invalid name %q: %v", q.Name, err)
+	}
+	class := q.Class
+	if class == 0 {
+		class = dnsmessage.ClassINET
+	}
+	id := uint16(rand.Intn(1 << 16))
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true, AuthenticData: q.DNSSEC})
+	b.EnableCompression()
+	_ = b.StartQuestions()
+	_ = b.Question(dnsmessage.Question{Name: name, Type: q.Type, Class: class})
+	_ = b.StartAdditionals()
+	var opt dnsmessage.ResourceHeader
+	_ = opt.SetEDNS0(1232, dnsmessage.RCodeSuccess, q.DNSSEC)
+	_ = b.OPTResource(opt, dnsmessage.OPTResource{})
+	msg, err := b.Finish()
+	if err != nil {
+		return Response{}, err
+	}
+
+	addr := withPort(server)
+	start := time.Now()
+	raw, err := roundTrip(addr, msg, q.TCP, timeout)
+	if err != nil {
+		return Response{Server: server}, err
+	}
+	resp, truncated, err := parseResponse(raw, id)
+	if err == nil && truncated && !q.TCP {
+		raw, err = roundTrip(addr, msg, true, timeout)
+		if err != nil {
+			return Response{Server: server}, err
+		}
+		resp, _, err = parseResponse(raw, id)
+	}
+	resp.Server = server
+	resp.RTT = time.Since(start)
+	return resp, err
+}
+
+func dnsName(s string) string {
+	if !strings.HasSuffix(s, ".") {
+		return s + "."
+	}
+	return s
+}
+
+func roundTrip(addr string, msg []byte, tcp bool, timeout time.Duration) ([]byte, error) {
+	network := "udp"
+	if tcp {
+		network = "tcp"
+	}
+	c, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(timeout))
+
+	if !tcp {
+		if _, err := c.Write(msg); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 65535)
+		n, err := c.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+
+	framed := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(framed, uint16(len(msg)))
+	copy(framed[2:], msg)
+	if _, err := c.Write(framed); err != nil {
+		return nil, err
+	}
+	var l [2]byte
+	if _, err := io.ReadFull(c, l[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(c, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func parseResponse(raw []byte, id uint16) (Response, bool, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(raw)
+	if err != nil {
+		return Response{}, false, err
+	}
+	if h.ID != id || !h.Response {
+		return Response{}, false, errors.New("mismatched DNS response")
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return Response{}, false, err
+	}
+	rrs, err := p.AllAnswers()
+	if err != nil {
+		return Response{}, false, err
+	}
+	resp := Response{RCode: h.RCode, AD: h.AuthenticData}
+	for _, rr := range rrs {
+		resp.Answers = append(resp.Answers, Record{
+			Name:  rr.Header.Name.String(),
+			Type:  rr.Header.Type,
+			TTL:   rr.Header.TTL,
+			Value: recordValue(rr.Body),
+		})
+	}
+	return resp, h.Truncated, nil
+}
+
+// Records returns the sorted values of the records matching qtype and
+// the lowest TTL among them.
+func Records(resp Response, qtype dnsmessage.Type) ([]string, uint32) {
+	var vals []string
+	var ttl uint32
+	for _, rr := range resp.Answers {
+		if rr.Type != qtype {
+			continue
+		}
+		if len(vals) == 0 || rr.TTL < ttl {
+			ttl = rr.TTL
+		}
+		vals = append(vals, rr.Value)
+	}
+	sort.Strings(vals)
+	return vals, ttl
+}
+
+func recordValue(body dnsmessage.ResourceBody) string {
+	switch b := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(b.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(b.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return b.CNAME.String()
+	case *dnsmessage.NSResource:
+		return b.NS.String()
+	case *dnsmessage.PTRResource:
+		return b.PTR.String()
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", b.Pref, b.MX.String())
+	case *dnsmessage.TXTResource:
+		return strings.Join(b.TXT, "")
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", b.Priority, b.Weight, b.Port, b.Target.String())
+	case *dnsmessage.SOAResource:
+		return fmt.Sprintf("%s %s %d", b.NS.String(), b.MBox.String(), b.Serial)
+	case *dnsmessage.UnknownResource:
+		if b.Type == TypeRRSIG && len(b.Data) >= 18 {
+			d := b.Data
+			return fmt.Sprintf("%s %d %d %d %s %s %d",
+				TypeName(dnsmessage.Type(binary.BigEndian.Uint16(d[0:]))), d[2], d[3],
+				binary.BigEndian.Uint32(d[4:]),
+				sigTime(binary.BigEndian.Uint32(d[8:])), sigTime(binary.BigEndian.Uint32(d[12:])),
+				binary.BigEndian.Uint16(d[16:]))
+		}
+	}
+	return fmt.Sprintf("%v", body)
+}
+
+// sigTime renders an RRSIG timestamp in presentation form (YYYYMMDDHHmmSS)
+func sigTime(v uint32) string {
+	return time.Unix(int64(v), 0).UTC().Format(SigTimeLayout)
+}
@@ -0,0 +1,120 @@
+package bench
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Validation is a resolver's DNSSEC validation capability
+type Validation int
+
+const (
+	Unreachable Validation = iota
+	Validating
+	NonValidating
+	Broken
+	Inconclusive // bogus zone fails but AD is not signalled
+)
+
+func (v Validation) String() string {
+	switch v {
+	case Validating:
+		return "validating"
+	case NonValidating:
+		return "non-validating"
+	case Broken:
+		return "broken"
+	case Inconclusive:
+		return "inconclusive"
+	}
+	return "unreachable"
+}
+
+// Zones used by the DNSSEC probe: one correctly signed, one with a
+// deliberately broken signature chain.
+var (
+	SignedZone = "isc.org"
+	BogusZone  = "dnssec-failed.org"
+)
+
+// DNSSECResult is the outcome of probing one profile
+type DNSSECResult struct {
+	Status Validation
+	Server string // server that answered the probe
+	Detail string
+}
+
+// ProbeDNSSEC queries the signed and bogus zones with DO set through the
+// first server that answers and classifies its validation behaviour:
+//   - validating: signed zone has AD, bogus zone is SERVFAIL
+//   - non-validating: no AD, bogus zone resolves
+//   - broken: signed zone fails, or AD is set while the bogus zone resolves
+//   - inconclusive: no AD, and SERVFAIL on the bogus zone, which a flaky
+//     upstream or a forwarder stripping AD shows as well
+func ProbeDNSSEC(servers []string, timeout time.Duration) DNSSECResult {
+	var lastErr error
+	for _, s := range servers {
+		good, err := exchange(s, Question{Name: SignedZone, Type: dnsmessage.TypeA, DNSSEC: true}, timeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		res := DNSSECResult{Server: s}
+		if good.RCode != dnsmessage.RCodeSuccess {
+			res.Status = Broken
+			res.Detail = fmt.Sprintf("%s returned %s", SignedZone, RCodeName(good.RCode))
+			return res
+		}
+		bogus, err := exchange(s, Question{Name: BogusZone, Type: dnsmessage.TypeA, DNSSEC: true}, timeout)
+		if err != nil {
+			res.Status = Broken
+			res.Detail = fmt.Sprintf("%s: %v", BogusZone, err)
+			return res
+		}
+		bogusFailed := bogus.RCode == dnsmessage.RCodeServerFailure
+		switch {
+		case good.AD && bogusFailed:
+			res.Status = Validating
+			res.Detail = "AD set on signed zone, SERVFAIL on bogus zone"
+		case !good.AD && bogusFailed:
+			res.Status = Inconclusive
+			res.Detail = "SERVFAIL on bogus zone, but AD is not signalled"
+		case good.AD:
+			res.Status = Broken
+			res.Detail = fmt.Sprintf("AD set on signed zone, but bogus zone returned %s", RCodeName(bogus.RCode))
+		default:
+			res.Status = NonValidating
+			res.Detail = fmt.Sprintf("no AD on signed zone, bogus zone returned %s", RCodeName(bogus.RCode))
+		}
+		return res
+	}
+	res := DNSSECResult{Status: Unreachable}
+	if lastErr != nil {
+		res.Detail = lastErr.Error()
+	}
+	return res
+}
+
+// ProbeAllDNSSEC runs ProbeDNSSEC for every profile concurrently
+func ProbeAllDNSSEC(targets map[string][]string, timeout time.Duration) map[string]DNSSECResult {
+	out := make(map[string]DNSSECResult, len(targets))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, servers := range targets {
+		wg.Add(1)
+		go func(name string, servers []string) {
+			defer wg.Done()
+			res := ProbeDNSSEC(servers, timeout)
+			mu.Lock()
+			out[name] = res
+			mu.Unlock()
+		}(name, servers)
+	}
+	wg.Wait()
+	return out
+}
@@ -0,0 +1,67 @@
+package bench
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func withZones(good, bogus Response) func() {
+	orig := exchange
+	exchange = func(server string, q Question, timeout time.Duration) (Response, error) {
+		if !q.DNSSEC {
+			return Response{}, errors.New("expected DO bit to be set")
+		}
+		if q.Name == BogusZone {
+			return bogus, nil
+		}
+		return good, nil
+	}
+	return func() { exchange = orig }
+}
+
+func TestProbeDNSSEC(t *testing.T) {
+	ok := Response{Answers: []Record{{Type: dnsmessage.TypeA, Value: "192.0.2.1"}}}
+	okAD := ok
+	okAD.AD = true
+	servfail := Response{RCode: dnsmessage.RCodeServerFailure}
+
+	testCases := []struct {
+		name     string
+		good     Response
+		bogus    Response
+		expected Validation
+	}{
+		{"validating", okAD, servfail, Validating},
+		// a flaky upstream or a forwarder stripping AD looks the same
+		{"SERVFAIL on bogus without AD", ok, servfail, Inconclusive},
+		{"non-validating", ok, ok, NonValidating},
+		{"AD but bogus resolves", okAD, ok, Broken},
+		{"signed zone fails", servfail, servfail, Broken},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer withZones(tc.good, tc.bogus)()
+			res := ProbeDNSSEC([]string{"192.0.2.53:53"}, time.Second)
+			if res.Status != tc.expected {
+				t.Errorf("Expected %s, got %s (%s)", tc.expected, res.Status, res.Detail)
+			}
+		})
+	}
+}
+
+func TestProbeDNSSECUnreachable(t *testing.T) {
+	orig := exchange
+	defer func() { exchange = orig }()
+	exchange = func(server string, q Question, timeout time.Duration) (Response, error) {
+		return Response{}, errors.New("i/o timeout")
+	}
+
+	res := ProbeDNSSEC([]string{"192.0.2.53:53", "192.0.2.54:53"}, time.Second)
+	if res.Status != Unreachable || res.Detail == "" {
+		t.Errorf("Expected unreachable with detail, got %+v", res)
+	}
+}
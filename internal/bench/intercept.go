@@ -0,0 +1,182 @@
+package bench
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Interception finding kinds
+const (
+	KindNXRewrite   = "nxdomain-rewrite"  // NXDOMAIN turned into an answer
+	KindIntercepted = "intercepted"       // vendor identity query not answered by the vendor
+	KindProxied     = "transparent-proxy" // different profiles share one egress resolver
+)
+
+// Finding is a single interception symptom
+type Finding struct {
+	Kind    string
+	Profile string
+	Server  string
+	Detail  string
+}
+
+// InterceptReport is the outcome of CheckIntercept
+type InterceptReport struct {
+	Findings []Finding
+	Egress   map[string][]string // "profile server" -> egress resolver addresses
+	Errors   map[string]error    // "profile server" -> query error
+}
+
+// Intercepted reports whether any symptom was found
+func (r InterceptReport) Intercepted() bool { return len(r.Findings) > 0 }
+
+// egressProbe returns the address of the recursive resolver that asked
+// Google's authoritative servers, whichever profile it was sent to.
+var egressProbe = Question{Name: "o-o.myaddr.l.google.com", Type: dnsmessage.TypeTXT}
+
+// identityProbes are queries only the named vendor's resolvers answer.
+var identityProbes = map[string]Question{
+	"cloudflare": {Name: "whoami.cloudflare", Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassCHAOS},
+	"quad9":      {Name: "id.server", Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassCHAOS},
+	"opendns":    {Name: "debug.opendns.com", Type: dnsmessage.TypeTXT},
+}
+
+// nxNames returns names that must not exist
+func nxNames() []string {
+	return []string{randomLabel() + ".example.com", randomLabel() + ".com"}
+}
+
+func randomLabel() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "dns-helper-" + hex.EncodeToString(b)
+}
+
+// CheckIntercept probes every server of every profile for signs of port 53
+// interception, NXDOMAIN rewriting and transparent proxying.
+func CheckIntercept(targets map[string][]string, timeout time.Duration) InterceptReport {
+	rep := InterceptReport{Egress: map[string][]string{}, Errors: map[string]error{}}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for profile, servers := range targets {
+		for _, s := range servers {
+			wg.Add(1)
+			go func(profile, server string) {
+				defer wg.Done()
+				findings, egress, err := probeServer(profile, server, timeout)
+				key := profile + " " + server
+				mu.Lock()
+				defer mu.Unlock()
+				rep.Findings = append(rep.Findings, findings...)
+				if len(egress) > 0 {
+					rep.Egress[key] = egress
+				}
+				if err != nil {
+					rep.Errors[key] = err
+				}
+			}(profile, s)
+		}
+	}
+	wg.Wait()
+	rep.Findings = append(rep.Findings, sharedEgress(rep.Egress)...)
+	sort.Slice(rep.Findings, func(i, j int) bool {
+		a, b := rep.Findings[i], rep.Findings[j]
+		if a.Profile != b.Profile {
+			return a.Profile < b.Profile
+		}
+		if a.Server != b.Server {
+			return a.Server < b.Server
+		}
+		return a.Kind < b.Kind
+	})
+	return rep
+}
+
+func probeServer(profile, server string, timeout time.Duration) ([]Finding, []string, error) {
+	var findings []Finding
+
+	for _, name := range nxNames() {
+		resp, err := exchange(server, Question{Name: name, Type: dnsmessage.TypeA}, timeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		if resp.RCode == dnsmessage.RCodeSuccess && len(resp.Answers) > 0 {
+			vals, _ := Records(resp, dnsmessage.TypeA)
+			findings = append(findings, Finding{KindNXRewrite, profile, server,
+				fmt.Sprintf("%s resolved to %s instead of NXDOMAIN", name, strings.Join(vals, ", "))})
+			break
+		}
+	}
+
+	if q, ok := identityProbes[profile]; ok {
+		resp, err := exchange(server, q, timeout)
+		if err != nil {
+			return findings, nil, err
+		}
+		if vals, _ := Records(resp, dnsmessage.TypeTXT); resp.RCode != dnsmessage.RCodeSuccess || len(vals) == 0 {
+			findings = append(findings, Finding{KindIntercepted, profile, server,
+				fmt.Sprintf("%s %s TXT got %s without an answer; port 53 is likely redirected",
+					q.Name, className(q.Class), RCodeName(resp.RCode))})
+		}
+	}
+
+	resp, err := exchange(server, egressProbe, timeout)
+	if err != nil {
+		return findings, nil, err
+	}
+	var egress []string
+	vals, _ := Records(resp, dnsmessage.TypeTXT)
+	for _, v := range vals {
+		if net.ParseIP(v) != nil {
+			egress = append(egress, v)
+		}
+	}
+	return findings, egress, nil
+}
+
+// sharedEgress flags egress resolvers seen behind more than one profile
+func sharedEgress(egress map[string][]string) []Finding {
+	profilesByIP := map[string]map[string]bool{}
+	for key, ips := range egress {
+		profile := strings.SplitN(key, " ", 2)[0]
+		for _, ip := range ips {
+			if profilesByIP[ip] == nil {
+				profilesByIP[ip] = map[string]bool{}
+			}
+			profilesByIP[ip][profile] = true
+		}
+	}
+	var out []Finding
+	for ip, profiles := range profilesByIP {
+		if len(profiles) < 2 {
+			continue
+		}
+		names := make([]string, 0, len(profiles))
+		for p := range profiles {
+			names = append(names, p)
+		}
+		sort.Strings(names)
+		for _, p := range names {
+			out = append(out, Finding{KindProxied, p, "",
+				fmt.Sprintf("queries egress from %s, shared with %s", ip, strings.Join(names, ", "))})
+		}
+	}
+	return out
+}
+
+func className(c dnsmessage.Class) string {
+	if c == dnsmessage.ClassCHAOS {
+		return "CH"
+	}
+	return "IN"
+}
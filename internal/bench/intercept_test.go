@@ -0,0 +1,90 @@
+package bench
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeResolver answers identity probes like an honest resolver with the
+// given egress address, optionally rewriting NXDOMAIN.
+func fakeResolver(egress string, rewriteNX bool, answerIdentity bool) func(Question) Response {
+	return func(q Question) Response {
+		switch {
+		case q.Name == egressProbe.Name:
+			return Response{Answers: []Record{{Type: dnsmessage.TypeTXT, Value: egress}}}
+		case strings.HasPrefix(q.Name, "dns-helper-"):
+			if rewriteNX {
+				return Response{Answers: []Record{{Type: dnsmessage.TypeA, Value: "203.0.113.80"}}}
+			}
+			return Response{RCode: dnsmessage.RCodeNameError}
+		default:
+			if answerIdentity {
+				return Response{Answers: []Record{{Type: dnsmessage.TypeTXT, Value: "IST"}}}
+			}
+			return Response{RCode: dnsmessage.RCodeRefused}
+		}
+	}
+}
+
+func withResolvers(servers map[string]func(Question) Response) func() {
+	orig := exchange
+	exchange = func(server string, q Question, timeout time.Duration) (Response, error) {
+		return servers[server](q), nil
+	}
+	return func() { exchange = orig }
+}
+
+func kinds(rep InterceptReport) map[string]int {
+	out := map[string]int{}
+	for _, f := range rep.Findings {
+		out[f.Kind]++
+	}
+	return out
+}
+
+func TestCheckInterceptClean(t *testing.T) {
+	defer withResolvers(map[string]func(Question) Response{
+		"1.1.1.1:53": fakeResolver("172.68.1.1", false, true),
+		"8.8.8.8:53": fakeResolver("74.125.1.1", false, true),
+	})()
+
+	rep := CheckIntercept(map[string][]string{
+		"cloudflare": {"1.1.1.1:53"},
+		"google":     {"8.8.8.8:53"},
+	}, time.Second)
+
+	if rep.Intercepted() {
+		t.Errorf("Expected no findings, got %+v", rep.Findings)
+	}
+	if len(rep.Egress) != 2 {
+		t.Errorf("Expected egress for 2 servers, got %v", rep.Egress)
+	}
+}
+
+func TestCheckInterceptTransparentProxy(t *testing.T) {
+	// every query ends up at the ISP resolver
+	isp := fakeResolver("100.64.0.53", true, false)
+	defer withResolvers(map[string]func(Question) Response{
+		"1.1.1.1:53": isp,
+		"8.8.8.8:53": isp,
+	})()
+
+	rep := CheckIntercept(map[string][]string{
+		"cloudflare": {"1.1.1.1:53"},
+		"google":     {"8.8.8.8:53"},
+	}, time.Second)
+
+	k := kinds(rep)
+	if k[KindProxied] != 2 {
+		t.Errorf("Expected both profiles flagged as proxied, got %v", k)
+	}
+	if k[KindNXRewrite] != 2 {
+		t.Errorf("Expected NXDOMAIN rewrite on both servers, got %v", k)
+	}
+	if k[KindIntercepted] != 1 {
+		t.Errorf("Expected cloudflare identity probe to fail, got %v", k)
+	}
+}
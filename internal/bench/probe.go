@@ -0,0 +1,105 @@
+package bench
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Outcome classes of a probe
+const (
+	ClassSuccess  = "success"  // NOERROR
+	ClassNXDomain = "nxdomain" // an answer, though not a useful one
+	ClassServFail = "servfail"
+	ClassRefused  = "refused"
+	ClassRCode    = "rcode"   // any other response code
+	ClassTimeout  = "timeout" // no reply in time
+	ClassNetwork  = "network" // unreachable, refused connection
+	ClassError    = "error"   // malformed reply and everything else
+)
+
+// Sample is the result of one probe query
+type Sample struct {
+	Time    time.Time     `json:"time"`
+	Profile string        `json:"profile"`
+	Server  string        `json:"server,omitempty"` // empty when the profile's servers are tried in order
+	QType   string        `json:"qtype,omitempty"`  // empty for address lookups (A and AAAA)
+	Domain  string        `json:"domain"`
+	Class   string        `json:"class"`
+	Latency time.Duration `json:"latency_ns,omitempty"` // zero without a reply
+}
+
+// Answered reports whether the server replied, whatever the response code
+func (s Sample) Answered() bool {
+	switch s.Class {
+	case ClassTimeout, ClassNetwork, ClassError:
+		return false
+	}
+	return true
+}
+
+// Classify sorts the result of an exchange into one of the classes
+func Classify(resp Response, err error) string {
+	if err != nil {
+		var ne net.Error
+		switch {
+		case errors.Is(err, os.ErrDeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+			return ClassTimeout
+		case errors.As(err, new(*net.OpError)):
+			return ClassNetwork
+		}
+		return ClassError
+	}
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+		return ClassSuccess
+	case dnsmessage.RCodeNameError:
+		return ClassNXDomain
+	case dnsmessage.RCodeServerFailure:
+		return ClassServFail
+	case dnsmessage.RCodeRefused:
+		return ClassRefused
+	}
+	return ClassRCode
+}
+
+// Probe queries every server of every target for each domain and type,
+// one goroutine per server, and returns one sample per query
+func Probe(targets map[string][]string, domains []string, qtypes []dnsmessage.Type, timeout time.Duration) []Sample {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var out []Sample
+	for profile, servers := range targets {
+		for _, server := range servers {
+			wg.Add(1)
+			go func(profile, server string) {
+				defer wg.Done()
+				var samples []Sample
+				for _, d := range domains {
+					for _, t := range qtypes {
+						samples = append(samples, probeOnce(profile, server, d, t, timeout))
+					}
+				}
+				mu.Lock()
+				out = append(out, samples...)
+				mu.Unlock()
+			}(profile, server)
+		}
+	}
+	wg.Wait()
+	return out
+}
+
+func probeOnce(profile, server, domain string, t dnsmessage.Type, timeout time.Duration) Sample {
+	s := Sample{Time: time.Now().UTC(), Profile: profile, Server: server, QType: TypeName(t), Domain: domain}
+	resp, err := exchange(server, Question{Name: domain, Type: t}, timeout)
+	s.Class = Classify(resp, err)
+	if s.Answered() {
+		s.Latency = resp.RTT
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package bench
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		resp     Response
+		err      error
+		expected string
+	}{
+		{Response{RCode: dnsmessage.RCodeSuccess}, nil, ClassSuccess},
+		{Response{RCode: dnsmessage.RCodeNameError}, nil, ClassNXDomain},
+		{Response{RCode: dnsmessage.RCodeServerFailure}, nil, ClassServFail},
+		{Response{RCode: dnsmessage.RCodeRefused}, nil, ClassRefused},
+		{Response{RCode: dnsmessage.RCodeNotImplemented}, nil, ClassRCode},
+		{Response{}, &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, ClassTimeout},
+		{Response{}, &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ClassNetwork},
+		{Response{}, errors.New("response ID mismatch"), ClassError},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.resp, tt.err); got != tt.expected {
+			t.Errorf("Classify(%v, %v) = %s, expected %s", tt.resp.RCode, tt.err, got, tt.expected)
+		}
+	}
+}
+
+func TestProbeSamples(t *testing.T) {
+	orig := exchange
+	defer func() { exchange = orig }()
+	exchange = func(server string, q Question, timeout time.Duration) (Response, error) {
+		if server == "192.0.2.1:53" {
+			return Response{Server: server}, &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}
+		}
+		return Response{Server: server, RTT: 12 * time.Millisecond}, nil
+	}
+
+	samples := Probe(map[string][]string{"test": {"1.1.1.1:53", "192.0.2.1:53"}}, []string{"example.com"},
+		[]dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}, time.Second)
+	if len(samples) != 4 {
+		t.Fatalf("Expected one sample per server, domain and type, got %d", len(samples))
+	}
+	for _, s := range samples {
+		switch {
+		case s.Server == "1.1.1.1:53" && (s.Class != ClassSuccess || s.Latency != 12*time.Millisecond):
+			t.Errorf("Unexpected sample %+v", s)
+		case s.Server == "192.0.2.1:53" && (s.Class != ClassTimeout || s.Latency != 0):
+			t.Errorf("A timeout has no latency: %+v", s)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package bench
+
+import (
+	"math"
+	"sort"
+)
+
+// MannWhitney tests whether two samples come from the same distribution,
+// without assuming one. It returns the two-sided p-value from the normal
+// approximation with tie and continuity correction, which is adequate
+// from about eight values per sample. Empty samples give 1.
+func MannWhitney(a, b []float64) float64 {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	type value struct {
+		v     float64
+		first bool
+	}
+	all := make([]value, 0, len(a)+len(b))
+	for _, v := range a {
+		all = append(all, value{v, true})
+	}
+	for _, v := range b {
+		all = append(all, value{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// average ranks over ties; ties also shrink the variance
+	var r1, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // ranks i+1..j
+		for k := i; k < j; k++ {
+			if all[k].first {
+				r1 += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+	n := n1 + n2
+	u := r1 - n1*(n1+1)/2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	z := math.Max(math.Abs(u-n1*n2/2)-0.5, 0) / sigma
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// ProportionTest compares two success rates, s1 of n1 against s2 of n2,
+// and returns the two-sided p-value of the pooled z-test
+func ProportionTest(s1, n1, s2, n2 int) float64 {
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	p := float64(s1+s2) / float64(n1+n2)
+	se := math.Sqrt(p * (1 - p) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return 1
+	}
+	z := math.Abs(float64(s1)/float64(n1)-float64(s2)/float64(n2)) / se
+	return math.Erfc(z / math.Sqrt2)
+}
@@ -0,0 +1,36 @@
+package bench
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMannWhitney(t *testing.T) {
+	// with ties, worked by hand: U=8, sigma=9.494, z=2.475
+	a := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	b := []float64{5, 6, 7, 8, 9, 10, 11, 12}
+	if p := MannWhitney(a, b); math.Abs(p-0.0133) > 0.0005 {
+		t.Errorf("Expected p=0.0133, got %.4f", p)
+	}
+	if p := MannWhitney(b, a); math.Abs(p-0.0133) > 0.0005 {
+		t.Errorf("The test is symmetric, got %.4f", p)
+	}
+	if p := MannWhitney(a, a); p != 1 {
+		t.Errorf("Identical samples must give p=1, got %.4f", p)
+	}
+	if p := MannWhitney(nil, a); p != 1 {
+		t.Errorf("An empty sample must give p=1, got %.4f", p)
+	}
+}
+
+func TestProportionTest(t *testing.T) {
+	if p := ProportionTest(100, 100, 80, 100); p > 0.001 {
+		t.Errorf("100%% vs 80%% of 100 should be significant, got %.4f", p)
+	}
+	if p := ProportionTest(15, 15, 14, 15); p < 0.05 {
+		t.Errorf("One failure in 15 should not be significant, got %.4f", p)
+	}
+	if p := ProportionTest(15, 15, 15, 15); p != 1 {
+		t.Errorf("Equal rates must give p=1, got %.4f", p)
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+var verbose bool
+var trace bool
+var auditLog string
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "print every external command with its exit code and duration")
+	rootCmd.PersistentFlags().BoolVar(&trace, "trace", false, "like --verbose, with the command's input and output")
+	rootCmd.PersistentFlags().StringVar(&auditLog, "audit-log", "", "append every external command as a JSON line to this file")
+}
+
+// setupAudit installs the sink for the audit flags, or none
+func setupAudit() {
+	if !verbose && !trace && auditLog == "" {
+		util.Audit = nil
+		return
+	}
+	var mu sync.Mutex
+	logFailed := false
+	util.Audit = func(inv util.Invocation) {
+		mu.Lock()
+		defer mu.Unlock()
+		if verbose || trace {
+			printInvocation(inv)
+		}
+		if auditLog != "" {
+			if err := appendInvocation(auditLog, inv); err != nil && !logFailed {
+				fmt.Fprintf(os.Stderr, "Note: could not write the audit log: %v\n", err)
+				logFailed = true
+			}
+		}
+	}
+}
+
+// printInvocation writes one command to stderr, so it does not mix with
+// output meant for scripts
+func printInvocation(inv util.Invocation) {
+	fmt.Fprintf(os.Stderr, "+ %s  (exit %d, %s)\n", strings.Join(inv.Argv, " "), inv.ExitCode, inv.Duration.Round(100*time.Microsecond))
+	if !trace {
+		return
+	}
+	for _, s := range []struct{ name, text string }{{"stdin", inv.Stdin}, {"stdout", inv.Stdout}, {"stderr", inv.Stderr}, {"error", inv.Err}} {
+		if s.text == "" {
+			continue
+		}
+		for _, line := range strings.Split(s.text, "\n") {
+			fmt.Fprintf(os.Stderr, "  %s| %s\n", s.name, line)
+		}
+	}
+}
+
+func appendInvocation(path string, inv util.Invocation) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -1,12 +1,22 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"runtime"
+	"slices"
 	"sort"
+	"strings"
 	"time"
 
+	"dns-helper/internal/baseline"
 	"dns-helper/internal/bench"
+	"dns-helper/internal/platform"
+	"dns-helper/internal/report"
 	"dns-helper/internal/resolvers"
+	"dns-helper/internal/series"
 
 	"github.com/spf13/cobra"
 )
@@ -14,26 +24,55 @@ import (
 var domains []string
 var runs int
 var timeout time.Duration
+var skipInterceptCheck bool
+var corpus string
+var reportPath string
+var saveBaseline string
+var compareBaseline string
+var thresholds baseline.Thresholds
+var benchDuration time.Duration
+var benchInterval time.Duration
+var storePath string
 
 func init() {
 	cmd := &cobra.Command{
 		Use:   "benchmark [profile|all]",
 		Short: "DNS resolver latency comparison",
 		Args:  cobra.ExactArgs(1),
+		// regressions are not usage errors; Execute prints the error
+		SilenceUsage:  true,
+		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			targets := map[string][]string{}
 			if args[0] == "all" {
-				for k, v := range resolvers.Presets {
-					targets[k] = v
+				for _, k := range resolvers.Names() {
+					p, _ := resolvers.Lookup(k)
+					targets[k] = p.Servers
 				}
 			} else {
-				if ips, ok := resolvers.Presets[args[0]]; ok {
-					targets[args[0]] = ips
+				if p, ok := resolvers.Lookup(args[0]); ok {
+					targets[args[0]] = p.Servers
 				} else {
 					return fmt.Errorf("profile not found: %s", args[0])
 				}
 			}
-			results := bench.Run(targets, domains, runs, timeout)
+			// --domains on the command line wins over a corpus
+			if corpus != "" && !cmd.Flags().Changed("domains") {
+				list, ok := cfg.Corpora[corpus]
+				if !ok || len(list) == 0 {
+					return fmt.Errorf("corpus not found in the configuration: %s", corpus)
+				}
+				domains = list
+			}
+			var results map[string]bench.Result
+			if benchDuration > 0 {
+				var err error
+				if results, err = runSeries(cmd.Context(), targets); err != nil {
+					return err
+				}
+			} else {
+				results = bench.Run(targets, domains, runs, timeout)
+			}
 			// print sorted results
 			keys := make([]string, 0, len(results))
 			for k := range results {
@@ -46,11 +85,176 @@ func init() {
 				fmt.Printf("- %-10s avg=%.1fms p50=%.1fms p90=%.1fms success=%d/%d\n",
 					name, r.AvgMS(), r.P50MS(), r.P90MS(), r.Successes, r.Total)
 			}
-			return nil
+			var regressions error
+			if compareBaseline != "" {
+				base, err := baseline.Load(compareBaseline)
+				if err != nil {
+					return err
+				}
+				regressions = printBaselineDeltas(base, results, keys)
+			}
+			if saveBaseline != "" {
+				if err := baseline.Save(baseline.New(saveBaseline, results, domains, runs, timeout)); err != nil {
+					return err
+				}
+				fmt.Printf("Saved baseline %s\n", saveBaseline)
+			}
+			if reportPath != "" {
+				if err := writeReport(results); err != nil {
+					return err
+				}
+				fmt.Printf("Report written to %s\n", reportPath)
+			}
+			if !skipInterceptCheck {
+				if rep := bench.CheckIntercept(targets, timeout); rep.Intercepted() {
+					fmt.Println("Warning: DNS interception detected, these numbers may not reflect the chosen resolvers:")
+					printFindings(rep)
+				}
+			}
+			return regressions
 		},
 	}
 	cmd.Flags().StringSliceVar(&domains, "domains", []string{"turk.net", "google.com", "cloudflare.com"}, "domains to test")
 	cmd.Flags().IntVar(&runs, "runs", 5, "number of queries per domain")
 	cmd.Flags().DurationVar(&timeout, "timeout", 1200*time.Millisecond, "single query timeout")
+	cmd.Flags().StringVar(&corpus, "corpus", "", "test the domains of a corpus from the configuration file")
+	cmd.Flags().DurationVar(&benchDuration, "duration", 0, "repeat the benchmark for this long, storing every round (e.g. 24h)")
+	cmd.Flags().DurationVar(&benchInterval, "interval", 5*time.Minute, "with --duration, time between the starts of rounds")
+	cmd.Flags().StringVar(&storePath, "store", "", "with --duration, time series file to append to (default: "+series.DefaultPath()+")")
+	cmd.Flags().StringVar(&reportPath, "report", "", "also write a self-contained HTML report with charts to this file")
+	cmd.Flags().StringVar(&saveBaseline, "save-baseline", "", "save the results as a named baseline")
+	cmd.Flags().StringVar(&compareBaseline, "compare-baseline", "", "compare the results with a named baseline and fail on regressions")
+	cmd.Flags().Float64Var(&thresholds.P50, "max-p50-increase", 20, "with --compare-baseline, fail when p50 rises significantly by more than this many percent")
+	cmd.Flags().Float64Var(&thresholds.P90, "max-p90-increase", 30, "with --compare-baseline, fail when p90 rises significantly by more than this many percent")
+	cmd.Flags().Float64Var(&thresholds.Success, "max-success-drop", 5, "with --compare-baseline, fail when the success rate drops significantly by more than this many points")
+	cmd.Flags().Float64Var(&thresholds.Alpha, "alpha", 0.05, "significance level for --compare-baseline")
+	cmd.Flags().BoolVar(&skipInterceptCheck, "skip-intercept-check", false, "do not probe for DNS interception after the run")
 	rootCmd.AddCommand(cmd)
 }
+
+// runSeries runs a benchmark round every interval for the duration and
+// appends each to the time series store. It returns every round combined;
+// Ctrl-C stops after the current round.
+func runSeries(ctx context.Context, targets map[string][]string) (map[string]bench.Result, error) {
+	if benchInterval <= 0 {
+		return nil, errors.New("--interval must be positive")
+	}
+	path := storePath
+	if path == "" {
+		path = series.DefaultPath()
+	}
+	end := time.Now().Add(benchDuration)
+	fmt.Printf("Benchmarking every %s for %s, appending rounds to %s\n", benchInterval, benchDuration, path)
+	total := map[string]bench.Result{}
+	for round := 1; ; round++ {
+		start := time.Now()
+		results := bench.Run(targets, domains, runs, timeout)
+		names := make([]string, 0, len(results))
+		for name := range results {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var samples []bench.Sample
+		var line []string
+		for _, name := range names {
+			r := results[name]
+			samples = append(samples, r.Samples...)
+			line = append(line, fmt.Sprintf("%s p50=%.1fms %d/%d", name, r.P50MS(), r.Successes, r.Total))
+			t := total[name]
+			t.Latencies = append(t.Latencies, r.Latencies...)
+			t.Samples = append(t.Samples, r.Samples...)
+			t.Successes += r.Successes
+			t.Total += r.Total
+			total[name] = t
+		}
+		if err := series.Append(path, start, samples); err != nil {
+			return nil, err
+		}
+		fmt.Printf("%s round %d: %s\n", start.Local().Format("15:04:05"), round, strings.Join(line, ", "))
+
+		next := start.Add(benchInterval)
+		if !next.Before(end) {
+			return total, nil
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Println("Stopped; the rounds so far are stored")
+			return total, nil
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// writeReport writes the HTML report for results with the run's metadata
+func writeReport(results map[string]bench.Result) error {
+	meta := report.Meta{
+		Time:     time.Now().UTC(),
+		Platform: runtime.GOOS + "/" + runtime.GOARCH,
+		Version:  version,
+		Domains:  domains,
+		Runs:     runs,
+		Timeout:  timeout,
+	}
+	meta.Host, _ = os.Hostname()
+	for name := range results {
+		meta.Profiles = append(meta.Profiles, name)
+	}
+	sort.Strings(meta.Profiles)
+	if links, err := platform.Details(); err == nil {
+		for _, l := range links {
+			if len(l.Servers) > 0 {
+				meta.Network = append(meta.Network, fmt.Sprintf("%s: %s", l.Iface, strings.Join(l.Servers, ", ")))
+			}
+		}
+	}
+	f, err := os.Create(reportPath)
+	if err != nil {
+		return err
+	}
+	if err := report.Write(f, meta, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// printBaselineDeltas prints the deltas against base, marking significant
+// changes, and returns an error naming the regressions past the thresholds
+func printBaselineDeltas(base baseline.Baseline, results map[string]bench.Result, names []string) error {
+	fmt.Printf("Compared with baseline %s (%s, runs=%d, timeout=%s): %v\n",
+		base.Name, base.Time.Local().Format("2006-01-02 15:04"), base.Runs, base.Timeout, base.Domains)
+	if !slices.Equal(base.Domains, domains) || base.Runs != runs || base.Timeout != timeout {
+		fmt.Println("Note: the baseline was measured with different domains, runs or timeout")
+	}
+	var failed []string
+	for _, d := range baseline.Compare(base, results, names) {
+		if d.Missing {
+			fmt.Printf("- %-10s not in the baseline\n", d.Profile)
+			continue
+		}
+		lat := marker(d.LatencyP)
+		fmt.Printf("- %-10s p50=%.1f->%.1fms (%+.1f%%)%s p90=%.1f->%.1fms (%+.1f%%)%s success=%.1f->%.1f%%%s\n", d.Profile,
+			d.Base.P50MS(), d.Current.P50MS(), d.P50Change, lat,
+			d.Base.P90MS(), d.Current.P90MS(), d.P90Change, lat,
+			d.BaseRate, d.Rate, marker(d.SuccessP))
+		for _, r := range d.Regressions(thresholds) {
+			failed = append(failed, d.Profile+" "+r)
+		}
+	}
+	fmt.Printf("  * significant at %g, ** at %g (latency: Mann-Whitney U; success: two-proportion z-test)\n", thresholds.Alpha, thresholds.Alpha/10)
+	if len(failed) > 0 {
+		return fmt.Errorf("regression against baseline %s: %s", base.Name, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// marker flags a p-value below the significance level
+func marker(p float64) string {
+	switch {
+	case p < thresholds.Alpha/10:
+		return "**"
+	case p < thresholds.Alpha:
+		return "*"
+	}
+	return ""
+}
@@ -73,7 +73,7 @@ func TestVersionCommand(t *testing.T) {
 
 func TestCommandStructure(t *testing.T) {
 	// Test that all expected commands exist
-	expectedCommands := []string{"switch", "status", "list", "benchmark", "version"}
+	expectedCommands := []string{"switch", "status", "list", "benchmark", "version", "flush", "exporter", "stats"}
 
 	for _, expected := range expectedCommands {
 		found := false
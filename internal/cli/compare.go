@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"dns-helper/internal/bench"
+	"dns-helper/internal/resolvers"
+
+	"github.com/spf13/cobra"
+)
+
+var compareType string
+var compareTimeout time.Duration
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "compare <name>...",
+		Short: "Compare answers for names across all profiles",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			qtype, err := bench.ParseType(compareType)
+			if err != nil {
+				return err
+			}
+			for i, name := range args {
+				if i > 0 {
+					fmt.Println()
+				}
+				cmp := bench.Compare(resolvers.Presets, name, qtype, compareTimeout)
+				printComparison(cmp)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&compareType, "type", "A", "record type to query (A, AAAA, CNAME, MX, NS, TXT, ...)")
+	cmd.Flags().DurationVar(&compareTimeout, "timeout", 2*time.Second, "single query timeout")
+	rootCmd.AddCommand(cmd)
+}
+
+func printComparison(cmp bench.Comparison) {
+	fmt.Printf("%s %s: %d distinct answer set(s)\n", cmp.Name, bench.TypeName(cmp.Type), len(cmp.Groups))
+	for i, g := range cmp.Groups {
+		answer := strings.Join(g.Answers, ", ")
+		if answer == "" {
+			answer = "(no records)"
+		}
+		flag := ""
+		if g.Filtered {
+			flag = "  [FILTERED]"
+		}
+		fmt.Printf("  [%d] %s %s%s\n", i+1, bench.RCodeName(g.RCode), answer, flag)
+		if g.MinTTL() != g.MaxTTL() {
+			fmt.Printf("      TTL differs: %d..%ds\n", g.MinTTL(), g.MaxTTL())
+		}
+		for _, m := range g.Members {
+			fmt.Printf("      - %-10s %-20s ttl=%-6d rtt=%s\n", m.Profile, m.Server, m.TTL, m.RTT.Round(time.Millisecond))
+		}
+	}
+	if len(cmp.Errors) > 0 {
+		keys := make([]string, 0, len(cmp.Errors))
+		for k := range cmp.Errors {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Println("  errors:")
+		for _, k := range keys {
+			fmt.Printf("      - %s: %v\n", k, cmp.Errors[k])
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"dns-helper/internal/config"
+	"dns-helper/internal/resolvers"
+	"dns-helper/internal/util"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+var configFlag string
+
+// cfg and cfgPath are the loaded configuration and where it came from
+var cfg config.Config
+var cfgPath string
+
+// cfgIgnored is set when a broken file was skipped for a configOptional
+// command
+var cfgIgnored bool
+
+// configOptional lists the commands that still run, with a warning, when
+// the configuration file is broken: they are how one finds out what is
+// wrong with it
+var configOptional = map[string]bool{"version": true, "help": true, "config show": true}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configFlag, "config", "", "configuration file (default: "+config.DefaultPath()+")")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := loadConfigFor(cmd); err != nil {
+			return err
+		}
+		if err := applyDefaults(cmd); err != nil {
+			return err
+		}
+		setupAudit()
+		util.SetContext(cmd.Context())
+		return nil
+	}
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration file",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration and where each value comes from",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := effectiveConfig()
+			if err != nil {
+				return err
+			}
+			fmt.Print(out)
+			return nil
+		},
+	})
+	rootCmd.AddCommand(cmd)
+}
+
+// loadConfig reads the configuration file, checks that it only names
+// existing commands and flags, and registers its profiles
+func loadConfig() error {
+	path, explicit := config.Path(configFlag)
+	c, err := config.Load(path, explicit)
+	if err != nil {
+		return err
+	}
+	for _, name := range c.CommandNames() {
+		cmd := findCommand(name)
+		if cmd == nil {
+			return fmt.Errorf("%s: unknown command %q", path, name)
+		}
+		for flag := range c.Commands[name] {
+			if cmd.Flag(flag) == nil {
+				return fmt.Errorf("%s: %s has no --%s flag", path, name, flag)
+			}
+		}
+	}
+	for name, p := range c.Profiles {
+		resolvers.Custom[name] = resolvers.Profile{Servers: p.Servers, Search: p.Search, Options: p.Options}
+	}
+	cfg, cfgPath = c, path
+	return nil
+}
+
+// loadConfigFor loads the configuration before cmd runs; commands in
+// configOptional continue without a broken file
+func loadConfigFor(cmd *cobra.Command) error {
+	err := loadConfig()
+	if err == nil || !configOptional[commandPath(cmd)] {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Warning: ignoring the configuration file: %v\n", err)
+	cfgPath, _ = config.Path(configFlag)
+	cfgIgnored = true
+	return nil
+}
+
+// findCommand returns the command at a path such as "route add"
+func findCommand(path string) *cobra.Command {
+	cmd, rest, err := rootCmd.Find(strings.Fields(path))
+	if err != nil || len(rest) > 0 || cmd == rootCmd {
+		return nil
+	}
+	return cmd
+}
+
+// commandPath is the path of cmd below the root, as used in the file
+func commandPath(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()+" ")
+}
+
+// setting finds the default for a flag of command. Precedence, after the
+// command line: the command's environment variable, the top-level one,
+// the command's entry in the file, then the top-level entry. Only
+// --backend and --audit-log have top-level settings.
+func setting(command, flag string) (value, source string, ok bool) {
+	if v, ok := config.Env(command, flag); ok {
+		return v, config.EnvName(command, flag), true
+	}
+	if config.IsTopLevel(flag) {
+		if v, ok := config.Env("", flag); ok {
+			return v, config.EnvName("", flag), true
+		}
+	}
+	if v, ok := cfg.Default(command, flag); ok {
+		return v, cfgPath, true
+	}
+	if v, ok := cfg.TopLevel(flag); ok {
+		return v, cfgPath, true
+	}
+	return "", "", false
+}
+
+// applyDefaults sets every flag of cmd not given on the command line from
+// the environment or the file. Flags stay unchanged, so Changed still
+// tells whether the user passed them. A default is skipped when the user
+// passed a flag it is mutually exclusive with, such as --runtime for a
+// configured persist: true.
+func applyDefaults(cmd *cobra.Command) error {
+	command := commandPath(cmd)
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" || f.Name == "config" || excluded(cmd, f) {
+			return
+		}
+		v, source, ok := setting(command, f.Name)
+		if !ok {
+			return
+		}
+		if serr := f.Value.Set(v); serr != nil {
+			err = fmt.Errorf("%s: --%s for %s: %v", source, f.Name, command, serr)
+		}
+	})
+	return err
+}
+
+// excluded reports whether a flag mutually exclusive with f was passed
+func excluded(cmd *cobra.Command, f *pflag.Flag) bool {
+	for _, group := range f.Annotations["cobra_annotation_mutually_exclusive"] {
+		for _, other := range strings.Fields(group) {
+			if other != f.Name && cmd.Flags().Changed(other) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// defaultProfile is the profile switch uses without arguments
+func defaultProfile() (string, bool) {
+	if v, ok := config.Env("", "profile"); ok && v != "" {
+		return v, true
+	}
+	return cfg.Profile, cfg.Profile != ""
+}
+
+// effectiveConfig renders the merged configuration as YAML, commenting
+// each value with its source
+func effectiveConfig() (string, error) {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	source := cfgPath
+	if _, err := os.Stat(cfgPath); err != nil {
+		source += " (not found)"
+	} else if cfgIgnored {
+		source += " (invalid, ignored)"
+	}
+	doc.HeadComment = "configuration file: " + source
+
+	for _, flag := range []string{"backend", "audit-log"} {
+		if v, ok := config.Env("", flag); ok {
+			addScalar(doc, flag, v, config.EnvName("", flag))
+		} else if v, ok := cfg.TopLevel(flag); ok {
+			addScalar(doc, flag, v, cfgPath)
+		}
+	}
+	if v, ok := defaultProfile(); ok {
+		addScalar(doc, "profile", v, profileSource())
+	}
+	if len(cfg.Profiles) > 0 {
+		addValue(doc, "profiles", cfg.Profiles)
+	}
+	if len(cfg.Corpora) > 0 {
+		addValue(doc, "corpora", cfg.Corpora)
+	}
+
+	commands := &yaml.Node{Kind: yaml.MappingNode}
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		cmds := c.Commands()
+		sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name() < cmds[j].Name() })
+		for _, sub := range cmds {
+			if !sub.IsAvailableCommand() || sub.Name() == "completion" {
+				continue
+			}
+			if flags := flagDefaults(sub); len(flags.Content) > 0 {
+				commands.Content = append(commands.Content, scalar(commandPath(sub)), flags)
+			}
+			walk(sub)
+		}
+	}
+	walk(rootCmd)
+	if len(commands.Content) > 0 {
+		doc.Content = append(doc.Content, scalar("commands"), commands)
+	}
+
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func profileSource() string {
+	if v, ok := config.Env("", "profile"); ok && v != "" {
+		return config.EnvName("", "profile")
+	}
+	return cfgPath
+}
+
+// flagDefaults lists the local flags of cmd with their effective defaults
+func flagDefaults(cmd *cobra.Command) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	command := commandPath(cmd)
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" {
+			return
+		}
+		value, source, ok := setting(command, f.Name)
+		if !ok {
+			value, source = f.DefValue, "default"
+		}
+		var v *yaml.Node
+		if strings.HasSuffix(f.Value.Type(), "Slice") {
+			v = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for _, item := range splitList(value) {
+				v.Content = append(v.Content, scalar(item))
+			}
+		} else {
+			v = scalar(value)
+		}
+		v.LineComment = source
+		node.Content = append(node.Content, scalar(f.Name), v)
+	})
+	return node
+}
+
+// splitList parses a list flag value, with or without pflag's brackets
+func splitList(s string) []string {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func scalar(v string) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.ScalarNode, Value: v}
+	if v == "" {
+		n.Style = yaml.DoubleQuotedStyle
+	}
+	return n
+}
+
+func addScalar(doc *yaml.Node, key, value, source string) {
+	v := scalar(value)
+	v.LineComment = source
+	doc.Content = append(doc.Content, scalar(key), v)
+}
+
+func addValue(doc *yaml.Node, key string, value any) {
+	var v yaml.Node
+	if err := v.Encode(value); err != nil {
+		return
+	}
+	doc.Content = append(doc.Content, scalar(key), &v)
+}
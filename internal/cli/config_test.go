@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-helper/internal/config"
+	"dns-helper/internal/resolvers"
+)
+
+func useConfig(t *testing.T, content string) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configFlag = path
+	t.Cleanup(func() {
+		configFlag, cfg, cfgPath = "", config.Config{}, ""
+		resolvers.Custom = map[string]resolvers.Profile{}
+	})
+	if err := loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	useConfig(t, "commands:\n  doctor:\n    timeout: 5s\n  compare:\n    timeout: 5s\n    type: MX\n")
+	t.Setenv("DNS_HELPER_COMPARE_TIMEOUT", "7s")
+	doctor := findCommand("doctor")
+	compare := findCommand("compare")
+	t.Cleanup(func() {
+		doctor.Flags().Lookup("timeout").Changed = false
+		doctorTimeout, compareTimeout, compareType = 2*time.Second, 2*time.Second, "A"
+	})
+
+	doctor.Flags().Set("timeout", "3s")
+	if err := applyDefaults(doctor); err != nil {
+		t.Fatal(err)
+	}
+	if doctorTimeout != 3*time.Second {
+		t.Errorf("The command line must win, got %s", doctorTimeout)
+	}
+
+	if err := applyDefaults(compare); err != nil {
+		t.Fatal(err)
+	}
+	if compareTimeout != 7*time.Second || compareType != "MX" {
+		t.Errorf("Expected the environment, then the file: timeout=%s type=%s", compareTimeout, compareType)
+	}
+}
+
+func TestConfigRejectsUnknownFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("commands:\n  benchmark:\n    rnus: 3\n"), 0644)
+	configFlag = path
+	t.Cleanup(func() { configFlag = "" })
+	if err := loadConfig(); err == nil {
+		t.Error("Expected an error for an unknown flag")
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	useConfig(t, "profile: work\nprofiles:\n  work:\n    servers: [10.0.0.53]\n")
+	if name, ok := defaultProfile(); !ok || name != "work" {
+		t.Errorf("Unexpected default profile %q", name)
+	}
+	if p, err := lookupProfile([]string{"work"}); err != nil || p.Servers[0] != "10.0.0.53" {
+		t.Errorf("Custom profile not registered: %+v %v", p, err)
+	}
+}
+
+func TestBrokenConfigOnlyFailsOtherCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("commands:\n  benchmark:\n    rnus: 3\n"), 0644)
+	configFlag = path
+	t.Cleanup(func() { configFlag, cfgPath, cfgIgnored = "", "", false })
+
+	for _, name := range []string{"version", "config show"} {
+		if err := loadConfigFor(findCommand(name)); err != nil {
+			t.Errorf("%s must run with a broken configuration, got %v", name, err)
+		}
+	}
+	if err := loadConfigFor(findCommand("doctor")); err == nil {
+		t.Error("Expected doctor to fail on a broken configuration")
+	}
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"dns-helper/internal/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorTimeout time.Duration
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Run end-to-end DNS troubleshooting checks",
+		// failing checks are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			failed := 0
+			for _, c := range doctor.Run(doctorTimeout) {
+				fmt.Printf("[%s] %-28s %s\n", c.Level, c.Name, c.Detail)
+				if c.Hint != "" {
+					fmt.Printf("       %-28s hint: %s\n", "", c.Hint)
+				}
+				if c.Level == doctor.Fail {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d check(s) failed", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&doctorTimeout, "timeout", 2*time.Second, "single query timeout")
+	rootCmd.AddCommand(cmd)
+}
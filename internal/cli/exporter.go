@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"dns-helper/internal/bench"
+	"dns-helper/internal/metrics"
+	"dns-helper/internal/platform"
+	"dns-helper/internal/resolvers"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var exporterListen string
+var exporterInterval time.Duration
+var exporterTimeout time.Duration
+var exporterProfiles []string
+var exporterDomains []string
+var exporterCorpus string
+var exporterTypes []string
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "exporter",
+		Short: "Probe resolvers on a schedule and serve Prometheus metrics",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := exporterTargets()
+			if err != nil {
+				return err
+			}
+			if exporterCorpus != "" && !cmd.Flags().Changed("domains") {
+				list, ok := cfg.Corpora[exporterCorpus]
+				if !ok || len(list) == 0 {
+					return fmt.Errorf("corpus not found in the configuration: %s", exporterCorpus)
+				}
+				exporterDomains = list
+			}
+			var qtypes []dnsmessage.Type
+			for _, s := range exporterTypes {
+				t, err := bench.ParseType(s)
+				if err != nil {
+					return err
+				}
+				qtypes = append(qtypes, t)
+			}
+			if exporterInterval <= 0 {
+				return errors.New("--interval must be positive")
+			}
+			return serveExporter(cmd.Context(), newExporter(), targets, qtypes)
+		},
+	}
+	cmd.Flags().StringVar(&exporterListen, "listen", ":9553", "address to serve /metrics on")
+	cmd.Flags().DurationVar(&exporterInterval, "interval", 30*time.Second, "time between probe rounds")
+	cmd.Flags().DurationVar(&exporterTimeout, "timeout", 2*time.Second, "single query timeout")
+	cmd.Flags().StringSliceVar(&exporterProfiles, "profile", nil, "profile to probe (repeatable; default: all)")
+	cmd.Flags().StringSliceVar(&exporterDomains, "domains", []string{"google.com", "cloudflare.com", "wikipedia.org"}, "domains to query")
+	cmd.Flags().StringVar(&exporterCorpus, "corpus", "", "query the domains of a corpus from the configuration file")
+	cmd.Flags().StringSliceVar(&exporterTypes, "qtype", []string{"A", "AAAA"}, "record types to query")
+	rootCmd.AddCommand(cmd)
+}
+
+// exporterTargets maps the profiles to probe to their servers
+func exporterTargets() (map[string][]string, error) {
+	names := exporterProfiles
+	if len(names) == 0 {
+		names = resolvers.Names()
+	}
+	targets := map[string][]string{}
+	for _, name := range names {
+		p, ok := resolvers.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("profile not found: %s", name)
+		}
+		targets[name] = p.Servers
+	}
+	return targets, nil
+}
+
+// exporter holds the metrics served on /metrics
+type exporter struct {
+	reg           *metrics.Registry
+	latency       *metrics.Histogram
+	queries       *metrics.Counter
+	active        *metrics.Gauge
+	rounds        *metrics.Counter
+	roundDuration *metrics.Gauge
+}
+
+func newExporter() *exporter {
+	reg := &metrics.Registry{}
+	return &exporter{
+		reg: reg,
+		latency: reg.Histogram("dns_helper_query_duration_seconds", "Round-trip time of answered probe queries.",
+			metrics.DefaultBuckets, "profile", "server", "qtype"),
+		queries: reg.Counter("dns_helper_queries_total", "Probe queries by outcome class.",
+			"profile", "server", "qtype", "class"),
+		active: reg.Gauge("dns_helper_active_resolver", "DNS servers currently configured on this host, by interface; profile is empty for servers of no profile.",
+			"interface", "server", "profile"),
+		rounds:        reg.Counter("dns_helper_probe_rounds_total", "Completed probe rounds."),
+		roundDuration: reg.Gauge("dns_helper_probe_round_duration_seconds", "Duration of the last probe round."),
+	}
+}
+
+// record adds one round of samples
+func (e *exporter) record(samples []bench.Sample) {
+	for _, s := range samples {
+		e.queries.Inc(s.Profile, s.Server, s.QType, s.Class)
+		if s.Answered() {
+			e.latency.Observe(s.Latency.Seconds(), s.Profile, s.Server, s.QType)
+		}
+	}
+}
+
+// setActive replaces the active resolver gauge with the host's servers
+func (e *exporter) setActive(status map[string][]string) {
+	e.active.Reset()
+	for iface, servers := range status {
+		for _, server := range servers {
+			e.active.Set(1, iface, server, profileOf(server))
+		}
+	}
+}
+
+// profileOf names the first profile, in name order, that lists server
+func profileOf(server string) string {
+	names := resolvers.Names()
+	sort.Strings(names)
+	for _, name := range names {
+		p, _ := resolvers.Lookup(name)
+		if slices.ContainsFunc(p.Servers, func(s string) bool { return hostOf(s) == hostOf(server) }) {
+			return name
+		}
+	}
+	return ""
+}
+
+func hostOf(server string) string {
+	if host, _, err := net.SplitHostPort(server); err == nil {
+		return host
+	}
+	return server
+}
+
+// round probes every target once and updates the metrics
+func (e *exporter) round(targets map[string][]string, qtypes []dnsmessage.Type) {
+	start := time.Now()
+	e.record(bench.Probe(targets, exporterDomains, qtypes, exporterTimeout))
+	if status, err := platform.Status(); err == nil {
+		e.setActive(status)
+	} else {
+		fmt.Printf("Reading the active resolvers failed: %v\n", err)
+	}
+	e.rounds.Inc()
+	e.roundDuration.Set(time.Since(start).Seconds())
+}
+
+// serveExporter serves /metrics and probes every interval until ctx is
+// canceled, as by Ctrl-C
+func serveExporter(ctx context.Context, e *exporter, targets map[string][]string, qtypes []dnsmessage.Type) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e.reg)
+	srv := &http.Server{Addr: exporterListen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	ln, err := net.Listen("tcp", exporterListen)
+	if err != nil {
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	fmt.Printf("Serving metrics on http://%s/metrics, probing %d profiles every %s\n", ln.Addr(), len(targets), exporterInterval)
+
+	ticker := time.NewTicker(exporterInterval)
+	defer ticker.Stop()
+	e.round(targets, qtypes)
+	for {
+		select {
+		case <-ticker.C:
+			e.round(targets, qtypes)
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return srv.Shutdown(shutdown)
+		}
+	}
+}
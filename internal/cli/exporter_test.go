@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"dns-helper/internal/bench"
+)
+
+func TestExporterMetrics(t *testing.T) {
+	e := newExporter()
+	e.record([]bench.Sample{
+		{Profile: "cloudflare", Server: "1.1.1.1:53", QType: "A", Class: bench.ClassSuccess, Latency: 20 * time.Millisecond},
+		{Profile: "cloudflare", Server: "1.1.1.1:53", QType: "A", Class: bench.ClassTimeout},
+	})
+	e.setActive(map[string][]string{"eth0": {"1.0.0.1"}, "wlan0": {"192.0.2.53"}})
+
+	var b strings.Builder
+	e.reg.WriteTo(&b)
+	out := b.String()
+	for _, line := range []string{
+		`dns_helper_queries_total{profile="cloudflare",server="1.1.1.1:53",qtype="A",class="success"} 1`,
+		`dns_helper_queries_total{profile="cloudflare",server="1.1.1.1:53",qtype="A",class="timeout"} 1`,
+		`dns_helper_query_duration_seconds_bucket{profile="cloudflare",server="1.1.1.1:53",qtype="A",le="0.025"} 1`,
+		`dns_helper_query_duration_seconds_count{profile="cloudflare",server="1.1.1.1:53",qtype="A"} 1`,
+		`dns_helper_active_resolver{interface="eth0",server="1.0.0.1",profile="cloudflare"} 1`,
+		`dns_helper_active_resolver{interface="wlan0",server="192.0.2.53",profile=""} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Missing %s in:\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"dns-helper/internal/platform"
+
+	"github.com/spf13/cobra"
+)
+
+var flushDryRun bool
+var noFlush bool
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "flush",
+		Short: "Flush local DNS caches",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return reportFlush(platform.FlushCaches(flushDryRun), flushDryRun)
+		},
+	}
+	cmd.Flags().BoolVar(&flushDryRun, "dry-run", false, "show what would happen without making changes")
+	rootCmd.AddCommand(cmd)
+}
+
+// reportFlush prints what was flushed and returns the failures
+func reportFlush(results []platform.Flushed, dryRun bool) error {
+	if len(results) == 0 {
+		fmt.Println("No local DNS cache found")
+		return nil
+	}
+	var errs []error
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			fmt.Printf("%-17s flush failed: %v\n", r.Cache, r.Err)
+			errs = append(errs, fmt.Errorf("%s: %w", r.Cache, r.Err))
+		case dryRun:
+			fmt.Printf("%-17s would be flushed\n", r.Cache)
+		default:
+			fmt.Printf("%-17s flushed\n", r.Cache)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flushAfter flushes caches once a change succeeded; a failed flush does
+// not fail the change
+func flushAfter(err error) error {
+	if err != nil || noFlush {
+		return err
+	}
+	fmt.Println("Flushing DNS caches...")
+	_ = reportFlush(platform.FlushCaches(dryRun), dryRun)
+	return nil
+}
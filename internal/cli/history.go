@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"slices"
+	"strings"
+
+	"dns-helper/internal/history"
+	"dns-helper/internal/platform"
+
+	"github.com/spf13/cobra"
+)
+
+var historyLimit int
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the DNS changes made with dns-helper",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := history.Load()
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				fmt.Println("No changes recorded")
+				return nil
+			}
+			if historyLimit > 0 && len(entries) > historyLimit {
+				entries = entries[len(entries)-historyLimit:]
+			}
+			for _, e := range entries {
+				printEntry(e)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&historyLimit, "limit", 20, "show only the last N changes (0 for all)")
+	rootCmd.AddCommand(cmd)
+
+	undoCmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Go back to the DNS settings before the last change",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return undo(cmd)
+		},
+	}
+	undoCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would happen without making changes")
+	undoCmd.Flags().BoolVar(&noFlush, "no-flush", false, "do not flush local DNS caches afterwards")
+	undoCmd.Flags().BoolVar(&useSudo, "sudo", false, "without the privileges needed, re-run the undo through sudo or pkexec")
+	rootCmd.AddCommand(undoCmd)
+}
+
+func printEntry(e history.Entry) {
+	line := fmt.Sprintf("%s  %-16s %-7s", e.Time.Local().Format("2006-01-02 15:04:05"), e.User, e.Command)
+	if e.Profile != "" {
+		line += " " + e.Profile
+	}
+	if len(e.Servers) > 0 {
+		line += fmt.Sprintf(" %v", e.Servers)
+	}
+	if e.Backend != "" {
+		line += " via " + e.Backend
+	}
+	if len(e.Interfaces) > 0 {
+		line += " on " + strings.Join(e.Interfaces, ",")
+	}
+	if e.OK() {
+		line += "  ok"
+	} else {
+		line += "  failed: " + e.Result
+	}
+	fmt.Println(line)
+}
+
+// recordChange runs change and logs it in the history together with a
+// snapshot of the settings before it. Dry runs are not recorded, and
+// failing to record does not fail the change.
+func recordChange(command, profile string, servers []string, change func() error) error {
+	if dryRun {
+		return change()
+	}
+	before, err := platform.Details()
+	var id string
+	if err == nil {
+		id, err = history.SaveSnapshot(before)
+	}
+	if err != nil {
+		fmt.Printf("Note: could not save a snapshot for undo: %v\n", err)
+		id = ""
+	}
+	err = change()
+
+	e := history.Entry{Command: command, Profile: profile, Servers: servers, Snapshot: id, Result: "ok"}
+	if err != nil {
+		e.Result = err.Error()
+	}
+	after, _ := platform.Details()
+	var backends []string
+	for _, l := range history.Changed(before, after) {
+		e.Interfaces = append(e.Interfaces, l.Iface)
+		for _, a := range after {
+			if a.Iface == l.Iface && a.Applied != nil && !slices.Contains(backends, a.Applied.Backend) {
+				backends = append(backends, a.Applied.Backend)
+			}
+		}
+	}
+	e.Backend = strings.Join(backends, ",")
+	if e.Backend == "" {
+		e.Backend = platform.ActiveBackend()
+	}
+	if aerr := history.Append(e); aerr != nil {
+		fmt.Printf("Note: could not record the change in the history: %v\n", aerr)
+	}
+	return err
+}
+
+// undo restores every link the last change touched to its snapshot
+func undo(cmd *cobra.Command) error {
+	entries, err := history.Load()
+	if err != nil {
+		return err
+	}
+	last, ok := history.LastChange(entries)
+	if !ok {
+		return errors.New("nothing to undo")
+	}
+	before, err := history.LoadSnapshot(last.Snapshot)
+	if err != nil {
+		return fmt.Errorf("snapshot of the last change: %w", err)
+	}
+	current, err := platform.Details()
+	if err != nil {
+		return err
+	}
+	var links []platform.Link
+	for _, l := range history.Changed(before, current) {
+		if len(last.Interfaces) == 0 || slices.Contains(last.Interfaces, l.Iface) {
+			links = append(links, l)
+		}
+	}
+	when := last.Time.Local().Format("2006-01-02 15:04:05")
+	if len(links) == 0 {
+		fmt.Printf("DNS settings already match the state before the last change (%s by %s at %s)\n", last.Command, last.User, when)
+		return nil
+	}
+	var change platform.Change
+	for _, l := range links {
+		if restoresServers(l) {
+			if change.Apply == nil {
+				change.Apply = &platform.Settings{}
+			}
+			change.Apply.Search = append(change.Apply.Search, l.Search()...)
+		} else {
+			change.Reset = true
+		}
+	}
+	if elevated, err := preflight(cmd, nil, platform.Options{DryRun: dryRun}, change); elevated || err != nil {
+		return err
+	}
+	fmt.Printf("Undoing %s by %s at %s\n", last.Command, last.User, when)
+	return flushAfter(recordChange("undo", "", nil, func() error {
+		var errs []error
+		for _, l := range links {
+			if err := restoreLink(l); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", l.Iface, err))
+			}
+		}
+		return errors.Join(errs...)
+	}))
+}
+
+// restoresServers reports whether undo sets the servers of snapshot link
+// l again rather than going back to DHCP. Outside Linux the servers
+// listed are the configured ones.
+func restoresServers(l platform.Link) bool {
+	return l.Applied != nil || (runtime.GOOS != "linux" && len(l.Servers) > 0)
+}
+
+// platform calls of restoreLink, replaced in tests
+var (
+	switchLink  = platform.SwitchAll
+	resetLink   = platform.ResetToDHCP
+	linkDetails = platform.Details
+)
+
+// restoreLink puts back the settings of one snapshot link. Servers set by
+// dns-helper are applied again; otherwise the link goes back to DHCP, and
+// servers DHCP does not bring back (static ones, or set by hand) are
+// applied again as well. On Linux "resolv.conf" and local resolvers are
+// not interfaces and are restored through the default target.
+func restoreLink(l platform.Link) error {
+	opts := platform.Options{DryRun: dryRun, Force: force}
+	if _, err := net.InterfaceByName(l.Iface); err == nil || runtime.GOOS != "linux" {
+		opts.Interfaces = []string{l.Iface}
+		if l.Applied != nil {
+			opts.Backend = l.Applied.Backend
+		}
+	}
+	settings := platform.Settings{Servers: l.Servers, Search: l.Search(), Options: l.Options}
+	if restoresServers(l) {
+		fmt.Printf("Restoring %s: %v\n", l.Iface, l.Servers)
+		return switchLink(settings, opts)
+	}
+	fmt.Printf("Restoring %s: DHCP\n", l.Iface)
+	if err := resetLink(opts); err != nil {
+		return err
+	}
+	if len(l.Servers) == 0 {
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("Note: %s had %v before the change; they are set again if DHCP does not provide them\n", l.Iface, l.Servers)
+		return nil
+	}
+	links, err := linkDetails()
+	if err != nil {
+		return fmt.Errorf("reading %s after the reset: %w", l.Iface, err)
+	}
+	for _, cur := range links {
+		if cur.Iface == l.Iface && slices.Equal(cur.Servers, l.Servers) {
+			return nil
+		}
+	}
+	fmt.Printf("Warning: DHCP did not bring back %v on %s; setting them again, as static servers\n", l.Servers, l.Iface)
+	return switchLink(settings, opts)
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"runtime"
+	"testing"
+
+	"dns-helper/internal/platform"
+)
+
+// fakeLinks replaces the platform calls of restoreLink; reset gives the
+// link dhcp as its servers
+func fakeLinks(t *testing.T, dhcp []string) (switched *[]platform.Settings) {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("outside Linux undo sets the listed servers without resetting")
+	}
+	oldSwitch, oldReset, oldDetails := switchLink, resetLink, linkDetails
+	t.Cleanup(func() { switchLink, resetLink, linkDetails = oldSwitch, oldReset, oldDetails })
+	switched = &[]platform.Settings{}
+	servers := []string{"1.1.1.1"}
+	switchLink = func(s platform.Settings, opts platform.Options) error {
+		*switched = append(*switched, s)
+		servers = s.Servers
+		return nil
+	}
+	resetLink = func(opts platform.Options) error {
+		servers = dhcp
+		return nil
+	}
+	linkDetails = func() ([]platform.Link, error) {
+		return []platform.Link{{Iface: "eth9", Servers: servers}}, nil
+	}
+	return switched
+}
+
+func TestRestoreLinkKeepsServersDHCPDoesNotProvide(t *testing.T) {
+	switched := fakeLinks(t, []string{"192.168.1.1"})
+	if err := restoreLink(platform.Link{Iface: "eth9", Servers: []string{"10.0.0.2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*switched) != 1 || (*switched)[0].Servers[0] != "10.0.0.2" {
+		t.Errorf("Expected the static 10.0.0.2 to be set again, got %v", *switched)
+	}
+}
+
+func TestRestoreLinkLeavesDHCPServers(t *testing.T) {
+	switched := fakeLinks(t, []string{"192.168.1.1"})
+	if err := restoreLink(platform.Link{Iface: "eth9", Servers: []string{"192.168.1.1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*switched) != 0 {
+		t.Errorf("DHCP already gives the snapshot's servers, but switched to %v", *switched)
+	}
+}
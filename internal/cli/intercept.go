@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"dns-helper/internal/bench"
+	"dns-helper/internal/resolvers"
+
+	"github.com/spf13/cobra"
+)
+
+var interceptTimeout time.Duration
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "check-intercept",
+		Short: "Detect DNS hijacking, NXDOMAIN rewriting and transparent proxies",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rep := bench.CheckIntercept(resolvers.Presets, interceptTimeout)
+
+			keys := make([]string, 0, len(rep.Egress))
+			for k := range rep.Egress {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			fmt.Println("Egress resolvers (as seen by o-o.myaddr.l.google.com):")
+			for _, k := range keys {
+				fmt.Printf("- %-30s -> %v\n", k, rep.Egress[k])
+			}
+			printInterceptErrors(rep)
+
+			if !rep.Intercepted() {
+				fmt.Println("No interception detected")
+				return nil
+			}
+			fmt.Println("Interception detected:")
+			printFindings(rep)
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&interceptTimeout, "timeout", 2*time.Second, "single query timeout")
+	rootCmd.AddCommand(cmd)
+}
+
+func printFindings(rep bench.InterceptReport) {
+	for _, f := range rep.Findings {
+		where := f.Profile
+		if f.Server != "" {
+			where += " " + f.Server
+		}
+		fmt.Printf("- [%s] %s: %s\n", f.Kind, where, f.Detail)
+	}
+}
+
+func printInterceptErrors(rep bench.InterceptReport) {
+	if len(rep.Errors) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(rep.Errors))
+	for k := range rep.Errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Println("Unreachable:")
+	for _, k := range keys {
+		fmt.Printf("- %-30s %v\n", k, rep.Errors[k])
+	}
+}
@@ -3,26 +3,50 @@ package cli
 import (
 	"fmt"
 	"sort"
+	"time"
 
+	"dns-helper/internal/bench"
 	"dns-helper/internal/resolvers"
 
 	"github.com/spf13/cobra"
 )
 
+var listDNSSEC bool
+
 func init() {
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List available DNS profiles",
 		Run: func(cmd *cobra.Command, args []string) {
-			names := make([]string, 0, len(resolvers.Presets))
-			for k := range resolvers.Presets {
-				names = append(names, k)
-			}
+			names := resolvers.Names()
 			sort.Strings(names)
+			profiles := map[string]resolvers.Profile{}
+			servers := map[string][]string{}
+			for _, n := range names {
+				profiles[n], _ = resolvers.Lookup(n)
+				servers[n] = profiles[n].Servers
+			}
+			var results map[string]bench.DNSSECResult
+			if listDNSSEC {
+				results = bench.ProbeAllDNSSEC(servers, 2*time.Second)
+			}
 			for _, n := range names {
-				fmt.Printf("- %-10s -> %v\n", n, resolvers.Presets[n])
+				p := profiles[n]
+				line := fmt.Sprintf("- %-10s -> %v", n, p.Servers)
+				if len(p.Search) > 0 {
+					line += fmt.Sprintf("  search=%v", p.Search)
+				}
+				if len(p.Options) > 0 {
+					line += fmt.Sprintf("  options=%v", p.Options)
+				}
+				if listDNSSEC {
+					r := results[n]
+					line += fmt.Sprintf("  dnssec=%s (%s)", r.Status, r.Detail)
+				}
+				fmt.Println(line)
 			}
 		},
 	}
+	cmd.Flags().BoolVar(&listDNSSEC, "dnssec", false, "probe each profile for DNSSEC validation")
 	rootCmd.AddCommand(cmd)
 }
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+
+	"dns-helper/internal/platform"
+
+	"github.com/spf13/cobra"
+)
+
+var routeIface string
+var routeDryRun bool
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "route",
+		Short: "Manage split DNS routes (per-domain resolvers)",
+	}
+
+	addCmd := &cobra.Command{
+		Use:   "add <domain> [profile|custom] [ip1 ip2 ...]",
+		Short: "Send queries for a domain to a profile's servers",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := lookupProfile(args[1:])
+			if err != nil {
+				return err
+			}
+			return platform.AddRoute(args[0], p.Servers, routeIface, routeDryRun)
+		},
+	}
+	addCmd.Flags().StringVar(&routeIface, "interface", "", "link to route on (default: the link that reaches the first server)")
+	addCmd.Flags().BoolVar(&routeDryRun, "dry-run", false, "show what would happen without making changes")
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <domain>",
+		Short: "Remove a split DNS route",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return platform.RemoveRoute(args[0], routeIface, routeDryRun)
+		},
+	}
+	removeCmd.Flags().StringVar(&routeIface, "interface", "", "only remove the route from this link")
+	removeCmd.Flags().BoolVar(&routeDryRun, "dry-run", false, "show what would happen without making changes")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List split DNS routes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			routes, err := platform.Routes()
+			if err != nil {
+				return err
+			}
+			if len(routes) == 0 {
+				fmt.Println("No split DNS routes configured")
+				return nil
+			}
+			for _, r := range routes {
+				fmt.Printf("- %-25s -> %-10s %v\n", r.Domain, r.Iface, r.Servers)
+			}
+			return nil
+		},
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show per-link DNS servers and domains",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			links, err := platform.Links()
+			if err != nil {
+				return err
+			}
+			for _, l := range links {
+				fmt.Printf("%-15s servers=%v domains=%v\n", l.Iface, l.Servers, l.Domains)
+			}
+			return nil
+		},
+	}
+
+	cmd.AddCommand(addCmd, removeCmd, listCmd, statusCmd)
+	rootCmd.AddCommand(cmd)
+}
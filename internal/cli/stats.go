@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"dns-helper/internal/bench"
+	"dns-helper/internal/series"
+
+	"github.com/spf13/cobra"
+)
+
+var statsStore string
+var statsSince time.Duration
+var statsProfiles []string
+var outageBelow float64
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Summarize the time series recorded by benchmark --duration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := statsStore
+			if path == "" {
+				path = series.DefaultPath()
+			}
+			var since time.Time
+			if statsSince > 0 {
+				since = time.Now().Add(-statsSince)
+			}
+			records, err := series.Load(path, since)
+			if err != nil {
+				return err
+			}
+			if len(statsProfiles) > 0 {
+				records = slices.DeleteFunc(records, func(r series.Record) bool { return !slices.Contains(statsProfiles, r.Profile) })
+			}
+			if len(records) == 0 {
+				fmt.Printf("No rounds recorded in %s for this selection\n", path)
+				return nil
+			}
+			printStats(path, records)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&statsStore, "store", "", "time series file (default: "+series.DefaultPath()+")")
+	cmd.Flags().DurationVar(&statsSince, "since", 0, "only rounds from this long ago on, such as 24h (default: all)")
+	cmd.Flags().StringSliceVar(&statsProfiles, "profile", nil, "only these profiles (repeatable)")
+	cmd.Flags().Float64Var(&outageBelow, "outage-below", 50, "success rate in percent under which a round counts as an outage")
+	rootCmd.AddCommand(cmd)
+}
+
+func printStats(path string, records []series.Record) {
+	first, last := records[0].Round.Local(), records[len(records)-1].Round.Local()
+	fmt.Printf("%s: %d queries in %d rounds, %s to %s\n", path, len(records), series.Rounds(records),
+		first.Format("2006-01-02 15:04"), last.Format("2006-01-02 15:04"))
+	profiles := series.Profiles(records)
+
+	fmt.Println("\nBy profile:")
+	byProfile := series.ByProfile(records)
+	for _, name := range profiles {
+		r := byProfile[name]
+		fmt.Printf("- %-10s avg=%.1fms p50=%.1fms p90=%.1fms success=%d/%d (%.1f%%)\n",
+			name, r.AvgMS(), r.P50MS(), r.P90MS(), r.Successes, r.Total, successRate(r))
+	}
+
+	fmt.Println("\nBy hour of day (local time), p50 ms / success %:")
+	header := fmt.Sprintf("  %-4s", "hour")
+	for _, name := range profiles {
+		header += fmt.Sprintf(" %16s", name)
+	}
+	fmt.Println(header)
+	for _, h := range series.ByHour(records, time.Local) {
+		row := fmt.Sprintf("  %02d  ", h.Hour)
+		for _, name := range profiles {
+			r, ok := h.Profiles[name]
+			if !ok {
+				row += fmt.Sprintf(" %16s", "-")
+				continue
+			}
+			row += fmt.Sprintf(" %16s", fmt.Sprintf("%.1f / %.1f", r.P50MS(), successRate(r)))
+		}
+		fmt.Println(row)
+	}
+
+	fmt.Printf("\nOutages (rounds with success below %g%%):\n", outageBelow)
+	outages := series.Outages(records, outageBelow)
+	if len(outages) == 0 {
+		fmt.Println("  none")
+	}
+	for _, o := range outages {
+		until := "ongoing at " + o.End.Local().Format("2006-01-02 15:04")
+		if o.Recovered {
+			until = "to " + o.End.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("- %-10s %s %s (%s, %d rounds): %s\n", o.Profile, o.Start.Local().Format("2006-01-02 15:04"), until,
+			o.End.Sub(o.Start).Round(time.Second), o.Rounds, failureSummary(o.Failures))
+	}
+}
+
+func successRate(r bench.Result) float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return 100 * float64(r.Successes) / float64(r.Total)
+}
+
+// failureSummary lists failure classes, most frequent first
+func failureSummary(failures map[string]int) string {
+	classes := make([]string, 0, len(failures))
+	for c := range failures {
+		classes = append(classes, c)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if failures[classes[i]] != failures[classes[j]] {
+			return failures[classes[i]] > failures[classes[j]]
+		}
+		return classes[i] < classes[j]
+	})
+	parts := make([]string, len(classes))
+	for i, c := range classes {
+		parts[i] = fmt.Sprintf("%s %d", c, failures[c])
+	}
+	return strings.Join(parts, ", ")
+}
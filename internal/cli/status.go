@@ -13,12 +13,29 @@ func init() {
 		Use:   "status",
 		Short: "Show active DNS settings",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			s, err := platform.Status()
+			links, err := platform.Details()
 			if err != nil {
 				return err
 			}
-			for iface, servers := range s {
-				fmt.Printf("%-15s -> %v\n", iface, servers)
+			for _, l := range links {
+				if len(l.Servers) == 0 && len(l.Search()) == 0 && len(l.Options) == 0 {
+					continue // links resolved knows about but nothing is set on
+				}
+				line := fmt.Sprintf("%-15s -> %v", l.Iface, l.Servers)
+				if search := l.Search(); len(search) > 0 {
+					line += fmt.Sprintf("  search=%v", search)
+				}
+				if len(l.Options) > 0 {
+					line += fmt.Sprintf("  options=%v", l.Options)
+				}
+				if a := l.Applied; a != nil {
+					if a.Current(l.Servers) {
+						line += fmt.Sprintf("  (set via %s; %s)", a.Backend, a.Durability)
+					} else {
+						line += fmt.Sprintf("  (changed since dns-helper set %v via %s)", a.Servers, a.Backend)
+					}
+				}
+				fmt.Println(line)
 			}
 			return nil
 		},
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"dns-helper/internal/platform"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var useSudo bool
+
+// preflight checks privileges before a change so it does not fail
+// halfway. With --sudo, missing privileges re-run the command as root and
+// elevated reports that the child made the change.
+func preflight(cmd *cobra.Command, args []string, opts platform.Options, change platform.Change) (elevated bool, err error) {
+	if opts.DryRun {
+		return false, nil
+	}
+	err = platform.CheckPrivileges(opts, change)
+	if !errors.Is(err, platform.ErrPrivileges) {
+		return false, err
+	}
+	if !useSudo {
+		return false, fmt.Errorf("%w; run as root or pass --sudo", err)
+	}
+	return true, elevate(cmd, args)
+}
+
+// elevate runs the command again through sudo, or pkexec without a
+// terminal, and waits for it
+func elevate(cmd *cobra.Command, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	var argv []string
+	switch {
+	case isTerminal(os.Stdin) && hasCommand("sudo"):
+		argv = []string{"sudo", "--", exe}
+	case hasCommand("pkexec"):
+		argv = []string{"pkexec", exe}
+	default:
+		return errors.New("--sudo needs sudo or pkexec")
+	}
+	argv = append(argv, elevatedArgs(cmd, args)...)
+	fmt.Printf("Re-running as root: %s\n", strings.Join(argv, " "))
+	c := exec.CommandContext(cmd.Context(), argv[0], argv[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s: %w", argv[0], err)
+	}
+	return nil
+}
+
+// elevatedArgs repeats only the change: the command, every flag that
+// differs from its default, whether set on the command line, in the
+// environment or in the file, and the configuration file for its
+// profiles. sudo clears the environment and root has its own
+// configuration directory, so nothing else carries over. Checks already
+// done, such as --require-dnssec, are not repeated.
+func elevatedArgs(cmd *cobra.Command, args []string) []string {
+	out := strings.Fields(commandPath(cmd))
+	if _, err := os.Stat(cfgPath); err == nil {
+		if abs, err := filepath.Abs(cfgPath); err == nil {
+			out = append(out, "--config="+abs)
+		}
+	}
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		switch f.Name {
+		case "help", "config", "sudo", "require-dnssec":
+			return
+		}
+		if !f.Changed && f.Value.String() == f.DefValue {
+			return
+		}
+		if strings.HasSuffix(f.Value.Type(), "Slice") {
+			for _, v := range splitList(f.Value.String()) {
+				out = append(out, "--"+f.Name+"="+v)
+			}
+			return
+		}
+		out = append(out, "--"+f.Name+"="+f.Value.String())
+	})
+	return append(out, args...)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
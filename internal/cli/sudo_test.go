@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestElevatedArgs(t *testing.T) {
+	useConfig(t, "commands:\n  switch:\n    search: [corp.example]\n")
+	cmd := findCommand("switch")
+	t.Cleanup(func() {
+		for _, name := range []string{"interface", "require-dnssec", "sudo"} {
+			cmd.Flags().Lookup(name).Changed = false
+		}
+		ifaces, requireDNSSEC, useSudo, searchDomains = nil, false, false, nil
+	})
+	cmd.Flags().Set("interface", "eth0")
+	cmd.Flags().Set("interface", "wlan0")
+	cmd.Flags().Set("require-dnssec", "true")
+	cmd.Flags().Set("sudo", "true")
+	if err := applyDefaults(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Join(elevatedArgs(cmd, []string{"custom", "10.0.0.53"}), " ")
+	expected := "switch --config=" + cfgPath + " --interface=eth0 --interface=wlan0 --search=corp.example custom 10.0.0.53"
+	if got != expected {
+		t.Errorf("Unexpected arguments:\n%s\nexpected:\n%s", got, expected)
+	}
+}
@@ -3,38 +3,79 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"dns-helper/internal/bench"
 	"dns-helper/internal/platform"
+	"dns-helper/internal/resolvconf"
 	"dns-helper/internal/resolvers"
 
 	"github.com/spf13/cobra"
 )
 
 var dryRun bool
+var requireDNSSEC bool
+var ifaces []string
+var allIfaces bool
+var force bool
+var persist bool
+var runtimeOnly bool
+var backendName string
+var searchDomains []string
+var resolverOptions []string
 
 func init() {
 	cmd := &cobra.Command{
 		Use:   "switch [profile|custom] [ip1 ip2 ...]",
 		Short: "Apply DNS profile or switch to custom IPs",
-		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var servers []string
-			if args[0] == "custom" {
-				if len(args) < 2 {
-					return errors.New("custom requires at least one IP address")
-				}
-				servers = args[1:]
-			} else {
-				p, ok := resolvers.Presets[args[0]]
+			if len(args) == 0 {
+				name, ok := defaultProfile()
 				if !ok {
-					return fmt.Errorf("unknown profile: %s (use 'dns-helper list' to see available profiles)", args[0])
+					return errors.New("no profile given and no default profile configured")
+				}
+				args = []string{name}
+			}
+			p, err := lookupProfile(args)
+			if err != nil {
+				return err
+			}
+			// flags replace the profile's search domains and options;
+			// configured defaults only fill in what the profile lacks
+			if cmd.Flags().Changed("search") || len(p.Search) == 0 {
+				p.Search = searchDomains
+			}
+			if cmd.Flags().Changed("option") || len(p.Options) == 0 {
+				p.Options = resolverOptions
+			}
+			for _, o := range p.Options {
+				if err := resolvconf.ValidOption(o); err != nil {
+					return err
+				}
+			}
+			if requireDNSSEC {
+				res := bench.ProbeDNSSEC(p.Servers, 2*time.Second)
+				if res.Status != bench.Validating {
+					return fmt.Errorf("refusing to switch: resolver is %s, not validating (%s)", res.Status, res.Detail)
 				}
-				servers = p
+				fmt.Printf("DNSSEC: %s validates (%s)\n", res.Server, res.Detail)
 			}
-			return platform.SwitchAll(servers, dryRun)
+			opts := platformOptions()
+			settings := platform.Settings{Servers: p.Servers, Search: p.Search, Options: p.Options}
+			if elevated, err := preflight(cmd, args, opts, platform.Change{Apply: &settings}); elevated || err != nil {
+				return err
+			}
+			return flushAfter(recordChange("switch", args[0], p.Servers, func() error {
+				return platform.SwitchAll(settings, opts)
+			}))
 		},
 	}
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would happen without making changes")
+	cmd.Flags().BoolVar(&requireDNSSEC, "require-dnssec", false, "only switch if the resolver validates DNSSEC")
+	cmd.Flags().StringSliceVar(&searchDomains, "search", nil, "search domain (repeatable; replaces the profile's)")
+	cmd.Flags().StringSliceVar(&resolverOptions, "option", nil, "resolver option such as ndots:5, timeout:2, attempts:3 or rotate (repeatable)")
+	addInterfaceFlags(cmd)
 	rootCmd.AddCommand(cmd)
 
 	// Add reset command
@@ -42,9 +83,57 @@ func init() {
 		Use:   "reset",
 		Short: "Reset DNS settings to DHCP defaults",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return platform.ResetToDHCP(dryRun)
+			opts := platformOptions()
+			if elevated, err := preflight(cmd, args, opts, platform.Change{Reset: true}); elevated || err != nil {
+				return err
+			}
+			return flushAfter(recordChange("reset", "", nil, func() error {
+				return platform.ResetToDHCP(opts)
+			}))
 		},
 	}
 	resetCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would happen without making changes")
+	addInterfaceFlags(resetCmd)
 	rootCmd.AddCommand(resetCmd)
 }
+
+func addInterfaceFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&ifaces, "interface", nil, "interface to configure (repeatable; default: the default route interface on Linux, all on macOS/Windows)")
+	cmd.Flags().BoolVar(&allIfaces, "all-interfaces", false, "configure every active interface")
+	cmd.MarkFlagsMutuallyExclusive("interface", "all-interfaces")
+	cmd.Flags().BoolVar(&force, "force", false, "edit /etc/resolv.conf even when another service manages it (Linux fallback)")
+	cmd.Flags().BoolVar(&persist, "persist", false, "only use a backend whose changes survive reboots and DHCP renewals")
+	cmd.Flags().BoolVar(&runtimeOnly, "runtime", false, "only use a backend whose changes are lost on reboot (Linux)")
+	cmd.MarkFlagsMutuallyExclusive("persist", "runtime")
+	cmd.Flags().BoolVar(&noFlush, "no-flush", false, "do not flush local DNS caches afterwards")
+	cmd.Flags().StringVar(&backendName, "backend", "", "try this backend first, such as netplan or NetworkManager (Linux)")
+	cmd.Flags().BoolVar(&useSudo, "sudo", false, "without the privileges needed, re-run the change through sudo or pkexec")
+}
+
+func platformOptions() platform.Options {
+	mode := platform.ModeAuto
+	if persist {
+		mode = platform.ModePersist
+	} else if runtimeOnly {
+		mode = platform.ModeRuntime
+	}
+	return platform.Options{DryRun: dryRun, Interfaces: ifaces, AllInterfaces: allIfaces, Force: force, Mode: mode, Backend: backendName}
+}
+
+// lookupProfile resolves "[profile]" or "custom ip..." to a profile
+func lookupProfile(args []string) (resolvers.Profile, error) {
+	if args[0] == "custom" {
+		if len(args) < 2 {
+			return resolvers.Profile{}, errors.New("custom requires at least one IP address")
+		}
+		return resolvers.Profile{Servers: args[1:]}, nil
+	}
+	p, ok := resolvers.Lookup(args[0])
+	if !ok {
+		return p, fmt.Errorf("unknown profile: %s (use 'dns-helper list' to see available profiles)", args[0])
+	}
+	if len(args) > 1 {
+		return p, fmt.Errorf("unexpected arguments after profile: %s", strings.Join(args[1:], " "))
+	}
+	return p, nil
+}
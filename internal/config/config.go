@@ -0,0 +1,149 @@
+package config
+
+// User configuration: ~/.config/dns-helper/config.yaml, or the file given
+// by --config or DNS_HELPER_CONFIG. It holds defaults only; flags and
+// DNS_HELPER_* environment variables take precedence over it.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts every environment variable dns-helper reads
+const EnvPrefix = "DNS_HELPER_"
+
+// Profile is a user-defined DNS profile
+type Profile struct {
+	Servers []string `yaml:"servers"`
+	Search  []string `yaml:"search,omitempty"`
+	Options []string `yaml:"options,omitempty"`
+}
+
+// Config is the contents of the configuration file
+type Config struct {
+	Backend  string              `yaml:"backend,omitempty"` // preferred Linux backend for switch and reset
+	Profile  string              `yaml:"profile,omitempty"` // used by switch without arguments
+	AuditLog string              `yaml:"audit-log,omitempty"`
+	Profiles map[string]Profile  `yaml:"profiles,omitempty"`
+	Corpora  map[string][]string `yaml:"corpora,omitempty"` // domain lists for benchmark --corpus
+	// Commands holds flag defaults by command path ("benchmark",
+	// "route add") and flag name without dashes
+	Commands map[string]map[string]any `yaml:"commands,omitempty"`
+}
+
+// DefaultPath is config.yaml in the user's configuration directory
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dns-helper", "config.yaml")
+}
+
+// Path picks the file to read: flag, then DNS_HELPER_CONFIG, then the
+// default. explicit is false for the default, which may be missing.
+func Path(flag string) (path string, explicit bool) {
+	if flag != "" {
+		return flag, true
+	}
+	if env := os.Getenv(EnvPrefix + "CONFIG"); env != "" {
+		return env, true
+	}
+	return DefaultPath(), false
+}
+
+// Load reads path; a missing file is an empty configuration unless explicit
+func Load(path string, explicit bool) (Config, error) {
+	var c Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+	for name, p := range c.Profiles {
+		if len(p.Servers) == 0 {
+			return c, fmt.Errorf("%s: profile %s has no servers", path, name)
+		}
+	}
+	return c, nil
+}
+
+// IsTopLevel reports whether flag has a top-level setting, in the file
+// and as DNS_HELPER_<FLAG>
+func IsTopLevel(flag string) bool {
+	return flag == "backend" || flag == "audit-log"
+}
+
+// TopLevel returns the top-level value for flag
+func (c Config) TopLevel(flag string) (string, bool) {
+	switch flag {
+	case "backend":
+		return c.Backend, c.Backend != ""
+	case "audit-log":
+		return c.AuditLog, c.AuditLog != ""
+	}
+	return "", false
+}
+
+// Default returns the configured default for a flag of the command at
+// path as flag syntax: lists are joined with commas
+func (c Config) Default(command, flag string) (string, bool) {
+	v, ok := c.Commands[command][flag]
+	if !ok {
+		return "", false
+	}
+	switch v := v.(type) {
+	case []any:
+		parts := make([]string, len(v))
+		for i, p := range v {
+			parts[i] = fmt.Sprint(p)
+		}
+		return strings.Join(parts, ","), true
+	case nil:
+		return "", true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+// Env returns the environment override for a flag of command, named
+// DNS_HELPER_<COMMAND>_<FLAG> in upper case with underscores
+func Env(command, flag string) (string, bool) {
+	return os.LookupEnv(EnvName(command, flag))
+}
+
+// EnvName is the environment variable for a flag of command; an empty
+// command gives a top-level setting such as DNS_HELPER_BACKEND
+func EnvName(command, flag string) string {
+	name := flag
+	if command != "" {
+		name = command + "_" + flag
+	}
+	r := strings.NewReplacer(" ", "_", "-", "_")
+	return EnvPrefix + strings.ToUpper(r.Replace(name))
+}
+
+// CommandNames lists the commands that have defaults, sorted
+func (c Config) CommandNames() []string {
+	out := make([]string, 0, len(c.Commands))
+	for k := range c.Commands {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func write(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := write(t, `backend: netplan
+profile: work
+profiles:
+  work:
+    servers: [10.0.0.53]
+    search: [corp.example]
+corpora:
+  tr: [turk.net, hurriyet.com.tr]
+commands:
+  benchmark:
+    runs: 10
+    domains: [a.example, b.example]
+    timeout: 2s
+`)
+	c, err := Load(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Backend != "netplan" || c.Profile != "work" || c.Profiles["work"].Search[0] != "corp.example" || len(c.Corpora["tr"]) != 2 {
+		t.Errorf("Unexpected configuration: %+v", c)
+	}
+	for flag, want := range map[string]string{"runs": "10", "domains": "a.example,b.example", "timeout": "2s"} {
+		if got, ok := c.Default("benchmark", flag); !ok || got != want {
+			t.Errorf("Default for %s = %q, expected %q", flag, got, want)
+		}
+	}
+	if _, ok := c.Default("benchmark", "skip-intercept-check"); ok {
+		t.Error("Unset flags must have no default")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := Load(write(t, "bakend: netplan\n"), true); err == nil || !strings.Contains(err.Error(), "bakend") {
+		t.Errorf("Expected an unknown key error, got %v", err)
+	}
+	if _, err := Load(write(t, "profiles:\n  empty: {}\n"), true); err == nil {
+		t.Error("A profile without servers must be rejected")
+	}
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	if _, err := Load(missing, true); err == nil {
+		t.Error("A missing explicit file must be an error")
+	}
+	if _, err := Load(missing, false); err != nil {
+		t.Errorf("A missing default file must be empty, got %v", err)
+	}
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv("DNS_HELPER_CONFIG", "/etc/dns-helper.yaml")
+	if p, explicit := Path("/tmp/flag.yaml"); p != "/tmp/flag.yaml" || !explicit {
+		t.Errorf("The flag must win, got %s", p)
+	}
+	if p, explicit := Path(""); p != "/etc/dns-helper.yaml" || !explicit {
+		t.Errorf("The environment must win over the default, got %s", p)
+	}
+	t.Setenv("DNS_HELPER_CONFIG", "")
+	if _, explicit := Path(""); explicit {
+		t.Error("The default path is not explicit")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	cases := map[[2]string]string{
+		{"benchmark", "runs"}:    "DNS_HELPER_BENCHMARK_RUNS",
+		{"route add", "dry-run"}: "DNS_HELPER_ROUTE_ADD_DRY_RUN",
+		{"", "backend"}:          "DNS_HELPER_BACKEND",
+	}
+	for in, want := range cases {
+		if got := EnvName(in[0], in[1]); got != want {
+			t.Errorf("EnvName(%q, %q) = %s, expected %s", in[0], in[1], got, want)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package doctor
+
+// End-to-end DNS troubleshooting checks built on the platform and bench
+// packages. Each check reports pass/warn/fail with a hint on how to fix it.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"dns-helper/internal/bench"
+	"dns-helper/internal/platform"
+	"dns-helper/internal/resolvconf"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Level is the outcome of a single check
+type Level int
+
+const (
+	Pass Level = iota
+	Warn
+	Fail
+)
+
+func (l Level) String() string {
+	switch l {
+	case Pass:
+		return "PASS"
+	case Warn:
+		return "WARN"
+	}
+	return "FAIL"
+}
+
+// Check is the result of one diagnostic
+type Check struct {
+	Name   string
+	Level  Level
+	Detail string
+	Hint   string // how to fix it, empty on pass
+}
+
+// Paths and probe names, replaced in tests
+var (
+	resolvConfPath = resolvconf.DefaultPath
+	stubPaths      = []string{
+		"/run/systemd/resolve/stub-resolv.conf",
+		"/run/systemd/resolve/resolv.conf",
+		"/usr/lib/systemd/resolv.conf",
+	}
+	probeName   = "example.com"
+	dualStack   = "google.com"
+	exchange    = bench.Exchange
+	status      = platform.Status
+	backendName = platform.ActiveBackend
+	now         = time.Now
+)
+
+// Run executes every check in order
+func Run(timeout time.Duration) []Check {
+	var checks []Check
+	checks = append(checks, checkBackend())
+	if runtime.GOOS == "linux" {
+		checks = append(checks, checkResolvConf())
+	}
+	servers, c := configuredServers()
+	if c != nil {
+		return append(checks, *c)
+	}
+	checks = append(checks, checkTransports(servers, timeout)...)
+	checks = append(checks, checkAAAA(servers[0], timeout))
+	checks = append(checks, checkSearchDomains(servers[0], timeout)...)
+	checks = append(checks, checkClock(servers[0], timeout))
+	return checks
+}
+
+// backendOwners lists, per resolv.conf owner, the backends whose changes
+// reach that file; any other backend is overridden by the owner
+var backendOwners = map[string][]string{
+	"systemd-resolved": {"systemd-resolved", "resolvectl", "NetworkManager", "NetworkManager (device)", "systemd-networkd", "netplan"},
+	"NetworkManager":   {"NetworkManager", "NetworkManager (device)"},
+	"resolvconf":       {"resolvconf"},
+	"dns-helper":       {"resolv.conf"},
+}
+
+func checkBackend() Check {
+	name := backendName()
+	c := Check{Name: "backend", Level: Pass, Detail: name + " manages DNS"}
+	if runtime.GOOS != "linux" {
+		return c
+	}
+	// a local resolver is detected through resolv.conf, so it always matches
+	if name == "dnsmasq" || name == "unbound" {
+		return c
+	}
+	info, err := resolvconf.Inspect(resolvConfPath)
+	if err == nil && info.ManagedBy != "" && !slices.Contains(backendOwners[info.ManagedBy], name) {
+		c.Level = Fail
+		c.Detail = fmt.Sprintf("%s would be used, but %s owns %s", name, info.ManagedBy, resolvConfPath)
+		c.Hint = "switch changes do not reach the resolver in use; let " + name + " own " + resolvConfPath + ", or pick a matching backend with 'switch --backend'"
+		return c
+	}
+	if name == "resolv.conf" {
+		c.Level = Warn
+		c.Detail = "no DNS service found; " + resolvConfPath + " is edited directly"
+		c.Hint = "a DHCP client may overwrite it; install systemd-resolved, NetworkManager or resolvconf"
+	}
+	return c
+}
+
+func checkResolvConf() Check {
+	c := Check{Name: "resolv.conf"}
+	fi, err := os.Lstat(resolvConfPath)
+	if err != nil {
+		c.Level, c.Detail = Fail, err.Error()
+		c.Hint = "create " + resolvConfPath + " or run 'dns-helper reset'"
+		return c
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		c.Level, c.Detail = Pass, resolvConfPath+" is a regular file"
+		if b := backendName(); b == "systemd-resolved" || b == "resolvectl" {
+			c.Level = Warn
+			c.Hint = "systemd-resolved is running but bypassed; link it: ln -sf /run/systemd/resolve/stub-resolv.conf " + resolvConfPath
+		}
+		return c
+	}
+	target, err := filepath.EvalSymlinks(resolvConfPath)
+	if err != nil {
+		c.Level, c.Detail = Fail, "dangling symlink: "+err.Error()
+		c.Hint = "point it at the stub: ln -sf /run/systemd/resolve/stub-resolv.conf " + resolvConfPath
+		return c
+	}
+	for _, stub := range stubPaths {
+		if target == stub {
+			c.Level, c.Detail = Pass, resolvConfPath+" -> "+target
+			return c
+		}
+	}
+	if info, err := resolvconf.Inspect(resolvConfPath); err == nil && info.ManagedBy == "resolvconf" && backendName() == "resolvconf" {
+		c.Level, c.Detail = Pass, resolvConfPath+" -> "+target+" (resolvconf)"
+		return c
+	}
+	c.Level, c.Detail = Warn, resolvConfPath+" -> "+target+" (not a systemd-resolved file)"
+	c.Hint = "make sure the tool that owns " + target + " is the one you expect to manage DNS"
+	return c
+}
+
+// configuredServers collects the unique servers reported by platform.Status
+func configuredServers() ([]string, *Check) {
+	st, err := status()
+	if err != nil {
+		return nil, &Check{Name: "servers", Level: Fail, Detail: err.Error(),
+			Hint: "check that the DNS service is running ('dns-helper status')"}
+	}
+	seen := map[string]bool{}
+	var out []string
+	keys := make([]string, 0, len(st))
+	for k := range st {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, s := range st[k] {
+			// resolvectl may append "#server-name" for DoT servers
+			s = strings.SplitN(s, "#", 2)[0]
+			if s != "" && !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil, &Check{Name: "servers", Level: Fail, Detail: "no DNS servers configured",
+			Hint: "run 'dns-helper reset' or 'dns-helper switch <profile>'"}
+	}
+	return out, nil
+}
+
+func checkTransports(servers []string, timeout time.Duration) []Check {
+	var checks []Check
+	for _, s := range servers {
+		for _, tcp := range []bool{false, true} {
+			proto := "udp"
+			if tcp {
+				proto = "tcp"
+			}
+			c := Check{Name: "server " + s + "/" + proto}
+			resp, err := exchange(s, bench.Question{Name: probeName, Type: dnsmessage.TypeA, TCP: tcp}, timeout)
+			switch {
+			case err != nil:
+				c.Level, c.Detail = Fail, err.Error()
+				c.Hint = "the server is unreachable over " + proto + "; check firewalls or switch profiles"
+			case resp.RCode != dnsmessage.RCodeSuccess:
+				c.Level, c.Detail = Warn, "answered "+bench.RCodeName(resp.RCode)+" for "+probeName
+				c.Hint = "the server answers but does not resolve public names; try 'dns-helper check-intercept'"
+			default:
+				c.Level, c.Detail = Pass, fmt.Sprintf("answered in %s", resp.RTT.Round(time.Millisecond))
+			}
+			checks = append(checks, c)
+		}
+	}
+	return checks
+}
+
+func checkAAAA(server string, timeout time.Duration) Check {
+	c := Check{Name: "aaaa"}
+	resp, err := exchange(server, bench.Question{Name: dualStack, Type: dnsmessage.TypeAAAA}, timeout)
+	if err != nil {
+		c.Level, c.Detail = Fail, err.Error()
+		c.Hint = "AAAA queries are dropped; check for middleboxes filtering IPv6 lookups"
+		return c
+	}
+	if vals, _ := bench.Records(resp, dnsmessage.TypeAAAA); len(vals) > 0 {
+		c.Level, c.Detail = Pass, dualStack+" AAAA -> "+vals[0]
+		return c
+	}
+	c.Level, c.Detail = Warn, dualStack+" AAAA returned "+bench.RCodeName(resp.RCode)+" with no records"
+	c.Hint = "the resolver strips AAAA answers; IPv6-only services will fail"
+	return c
+}
+
+func checkSearchDomains(server string, timeout time.Duration) []Check {
+	domains := searchDomains()
+	if len(domains) == 0 {
+		return []Check{{Name: "search domains", Level: Pass, Detail: "none configured"}}
+	}
+	var checks []Check
+	for _, d := range domains {
+		c := Check{Name: "search " + d}
+		resp, err := exchange(server, bench.Question{Name: d, Type: dnsmessage.TypeSOA}, timeout)
+		switch {
+		case err != nil:
+			c.Level, c.Detail = Fail, err.Error()
+			c.Hint = "the server for this domain is unreachable; is the VPN up?"
+		case resp.RCode == dnsmessage.RCodeNameError:
+			c.Level, c.Detail = Fail, d+" does not exist for "+server
+			c.Hint = "remove the search domain or route it to an internal resolver ('dns-helper route add')"
+		case resp.RCode != dnsmessage.RCodeSuccess:
+			c.Level, c.Detail = Warn, d+" returned "+bench.RCodeName(resp.RCode)
+			c.Hint = "short names under this domain may not resolve"
+		default:
+			c.Level, c.Detail = Pass, d+" resolves"
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// searchDomains reads the search list from resolv.conf
+func searchDomains() []string {
+	f, err := resolvconf.Read(resolvConfPath)
+	if err != nil {
+		return nil
+	}
+	return f.Search()
+}
+
+// checkClock compares the local clock with the validity window of the
+// root zone's SOA signature; DNSSEC validation fails outside it.
+func checkClock(server string, timeout time.Duration) Check {
+	c := Check{Name: "clock"}
+	resp, err := exchange(server, bench.Question{Name: ".", Type: dnsmessage.TypeSOA, DNSSEC: true}, timeout)
+	if err != nil {
+		c.Level, c.Detail = Warn, "could not fetch a signature to compare against: "+err.Error()
+		return c
+	}
+	for _, rr := range resp.Answers {
+		if rr.Type != bench.TypeRRSIG {
+			continue
+		}
+		inception, expiration, ok := sigWindow(rr.Value)
+		if !ok {
+			continue
+		}
+		return clockWithin(now(), inception, expiration)
+	}
+	c.Level, c.Detail = Warn, "no RRSIG returned for the root zone; cannot verify the clock"
+	c.Hint = "the resolver strips DNSSEC records; compare 'date -u' with a trusted source"
+	return c
+}
+
+func sigWindow(value string) (time.Time, time.Time, bool) {
+	f := strings.Fields(value)
+	if len(f) < 6 {
+		return time.Time{}, time.Time{}, false
+	}
+	exp, err1 := time.Parse(bench.SigTimeLayout, f[4])
+	inc, err2 := time.Parse(bench.SigTimeLayout, f[5])
+	if err1 != nil || err2 != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return inc, exp, true
+}
+
+func clockWithin(t, inception, expiration time.Time) Check {
+	c := Check{Name: "clock"}
+	switch {
+	case t.Before(inception):
+		c.Level = Fail
+		c.Detail = fmt.Sprintf("local clock %s is before signature inception %s", t.UTC().Format(time.RFC3339), inception.Format(time.RFC3339))
+	case t.After(expiration):
+		c.Level = Fail
+		c.Detail = fmt.Sprintf("local clock %s is after signature expiration %s", t.UTC().Format(time.RFC3339), expiration.Format(time.RFC3339))
+	default:
+		c.Level, c.Detail = Pass, "local clock is within the root zone signature window"
+		return c
+	}
+	c.Hint = "DNSSEC validation will fail; enable time sync (e.g. 'timedatectl set-ntp true')"
+	return c
+}
@@ -0,0 +1,138 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"dns-helper/internal/bench"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestCheckResolvConfStubSymlink(t *testing.T) {
+	dir := t.TempDir()
+	stub := filepath.Join(dir, "stub-resolv.conf")
+	if err := os.WriteFile(stub, []byte("nameserver 127.0.0.53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "resolv.conf")
+	if err := os.Symlink(stub, link); err != nil {
+		t.Fatal(err)
+	}
+
+	origPath, origStubs := resolvConfPath, stubPaths
+	defer func() { resolvConfPath, stubPaths = origPath, origStubs }()
+	resolvConfPath, stubPaths = link, []string{stub}
+
+	if c := checkResolvConf(); c.Level != Pass {
+		t.Errorf("Expected stub symlink to pass, got %s: %s", c.Level, c.Detail)
+	}
+
+	stubPaths = []string{"/nonexistent"}
+	if c := checkResolvConf(); c.Level != Warn || c.Hint == "" {
+		t.Errorf("Expected foreign symlink to warn with a hint, got %s: %s", c.Level, c.Detail)
+	}
+}
+
+func TestCheckResolvConfMissing(t *testing.T) {
+	orig := resolvConfPath
+	defer func() { resolvConfPath = orig }()
+	resolvConfPath = filepath.Join(t.TempDir(), "missing")
+
+	if c := checkResolvConf(); c.Level != Fail {
+		t.Errorf("Expected missing resolv.conf to fail, got %s", c.Level)
+	}
+}
+
+func TestClockWithin(t *testing.T) {
+	inception := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	expiration := time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)
+
+	if c := clockWithin(inception.Add(time.Hour), inception, expiration); c.Level != Pass {
+		t.Errorf("Expected clock inside window to pass, got %s", c.Level)
+	}
+	if c := clockWithin(inception.Add(-time.Hour), inception, expiration); c.Level != Fail {
+		t.Errorf("Expected clock before inception to fail, got %s", c.Level)
+	}
+	if c := clockWithin(expiration.Add(time.Hour), inception, expiration); c.Level != Fail {
+		t.Errorf("Expected clock after expiration to fail, got %s", c.Level)
+	}
+}
+
+func TestCheckClockParsesRRSIG(t *testing.T) {
+	origExchange, origNow := exchange, now
+	defer func() { exchange, now = origExchange, origNow }()
+
+	exchange = func(server string, q bench.Question, timeout time.Duration) (bench.Response, error) {
+		return bench.Response{Answers: []bench.Record{
+			{Type: dnsmessage.TypeSOA, Value: "a.root-servers.net. nstld.verisign-grs.com. 2026101900"},
+			{Type: bench.TypeRRSIG, Value: "SOA 8 0 86400 20261101050000 20261019040000 46441"},
+		}}, nil
+	}
+	now = func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) }
+	if c := checkClock("127.0.0.53", time.Second); c.Level != Pass {
+		t.Errorf("Expected pass, got %s: %s", c.Level, c.Detail)
+	}
+
+	now = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) }
+	if c := checkClock("127.0.0.53", time.Second); c.Level != Fail {
+		t.Errorf("Expected fail for a clock in the past, got %s: %s", c.Level, c.Detail)
+	}
+}
+
+func TestConfiguredServersDeduplicates(t *testing.T) {
+	orig := status
+	defer func() { status = orig }()
+	status = func() (map[string][]string, error) {
+		return map[string][]string{
+			"systemd-resolved": {"1.1.1.1#cloudflare-dns.com", "1.0.0.1"},
+			"resolv.conf":      {"1.1.1.1"},
+		}, nil
+	}
+
+	servers, c := configuredServers()
+	if c != nil {
+		t.Fatalf("Unexpected failed check: %+v", c)
+	}
+	if len(servers) != 2 {
+		t.Errorf("Expected 2 unique servers, got %v", servers)
+	}
+}
+
+func TestCheckBackend(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resolv.conf ownership is only checked on Linux")
+	}
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	origPath, origName := resolvConfPath, backendName
+	defer func() { resolvConfPath, backendName = origPath, origName }()
+	resolvConfPath = path
+
+	cases := []struct {
+		name     string
+		header   string
+		backend  string
+		expected Level
+	}{
+		{"resolved owns it", "# This is managed by man:systemd-resolved(8).", "systemd-resolved", Pass},
+		{"NetworkManager feeds resolved", "# This is managed by man:systemd-resolved(8).", "NetworkManager", Pass},
+		{"NetworkManager owns it", "# Generated by NetworkManager", "systemd-resolved", Fail},
+		{"direct edit of a managed file", "# This is managed by man:systemd-resolved(8).", "resolv.conf", Fail},
+		{"no service", "", "resolv.conf", Warn},
+		{"local resolver", "# Generated by NetworkManager", "dnsmasq", Pass},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(c.header+"\nnameserver 127.0.0.53\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			backendName = func() string { return c.backend }
+			if got := checkBackend(); got.Level != c.expected {
+				t.Errorf("Expected %s, got %s: %s", c.expected, got.Level, got.Detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package history
+
+// Append-only log of DNS changes. Every switch, reset and undo adds one
+// JSON line; the DNS state from before the change is kept as a snapshot
+// next to it, so undo can go back to it.
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+
+	"dns-helper/internal/platform"
+)
+
+// Dir holds history.jsonl and the snapshots, replaced in tests
+var Dir = defaultDir()
+
+func defaultDir() string {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(os.Getenv("ProgramData"), "dns-helper")
+	case "darwin":
+		return "/Library/Application Support/dns-helper"
+	}
+	return "/var/lib/dns-helper"
+}
+
+// Entry is one change
+type Entry struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Command    string    `json:"command"` // switch, reset or undo
+	Profile    string    `json:"profile,omitempty"`
+	Servers    []string  `json:"servers,omitempty"`
+	Backend    string    `json:"backend,omitempty"`
+	Interfaces []string  `json:"interfaces,omitempty"` // links whose DNS changed
+	Result     string    `json:"result"`               // "ok" or the error
+	Snapshot   string    `json:"snapshot,omitempty"`   // state before the change
+}
+
+// OK reports whether the change succeeded
+func (e Entry) OK() bool { return e.Result == "ok" }
+
+func logPath() string {
+	return filepath.Join(Dir, "history.jsonl")
+}
+
+func snapshotPath(id string) string {
+	return filepath.Join(Dir, "snapshots", id+".json")
+}
+
+// Append adds e to the log, filling in the time and user
+func Append(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.User == "" {
+		e.User = currentUser()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(logPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load returns every entry, oldest first. A missing log is empty.
+func Load() ([]Entry, error) {
+	f, err := os.Open(logPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []Entry
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return out, fmt.Errorf("%s:%d: %v", logPath(), n, err)
+		}
+		out = append(out, e)
+	}
+	return out, sc.Err()
+}
+
+// SaveSnapshot stores links and returns the reference to put in an Entry
+func SaveSnapshot(links []platform.Link) (string, error) {
+	id := time.Now().UTC().Format("20060102T150405.000000000Z")
+	data, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(snapshotPath(id)), 0755); err != nil {
+		return "", err
+	}
+	return id, os.WriteFile(snapshotPath(id), append(data, '\n'), 0644)
+}
+
+// LoadSnapshot reads a snapshot saved by SaveSnapshot
+func LoadSnapshot(id string) ([]platform.Link, error) {
+	data, err := os.ReadFile(snapshotPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var links []platform.Link
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, fmt.Errorf("%s: %v", snapshotPath(id), err)
+	}
+	return links, nil
+}
+
+// LastChange returns the most recent change with a snapshot; a failed
+// one counts too, since it may have changed some links
+func LastChange(entries []Entry) (Entry, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if e := entries[i]; e.Snapshot != "" {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Changed returns the links of before whose servers or domains differ in
+// after, or that are missing from it
+func Changed(before, after []platform.Link) []platform.Link {
+	now := map[string]platform.Link{}
+	for _, l := range after {
+		now[l.Iface] = l
+	}
+	var out []platform.Link
+	for _, l := range before {
+		cur, ok := now[l.Iface]
+		if !ok || !slices.Equal(l.Servers, cur.Servers) || !slices.Equal(l.Domains, cur.Domains) || !slices.Equal(l.Options, cur.Options) {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// currentUser names who made the change, including who ran sudo
+func currentUser() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if s := os.Getenv("SUDO_USER"); s != "" && s != name {
+		name = s + " (as " + name + ")"
+	}
+	return name
+}
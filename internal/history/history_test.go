@@ -0,0 +1,74 @@
+package history
+
+import (
+	"strings"
+	"testing"
+
+	"dns-helper/internal/platform"
+)
+
+func useDir(t *testing.T) {
+	orig := Dir
+	Dir = t.TempDir()
+	t.Cleanup(func() { Dir = orig })
+}
+
+func TestAppendAndLoad(t *testing.T) {
+	useDir(t)
+	if entries, err := Load(); err != nil || len(entries) != 0 {
+		t.Fatalf("Expected an empty history, got %v %v", entries, err)
+	}
+	Append(Entry{Command: "switch", Profile: "cloudflare", Servers: []string{"1.1.1.1"}, Result: "ok", Snapshot: "a"})
+	Append(Entry{Command: "reset", Result: "exit status 1"})
+	entries, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Profile != "cloudflare" || entries[1].OK() {
+		t.Fatalf("Unexpected entries: %+v", entries)
+	}
+	if entries[0].Time.IsZero() || entries[0].User == "" {
+		t.Errorf("Time and user must be filled in: %+v", entries[0])
+	}
+	if e, ok := LastChange(entries); !ok || e.Snapshot != "a" {
+		t.Errorf("Expected the entry with a snapshot, got %+v", e)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	useDir(t)
+	links := []platform.Link{
+		{Iface: "eth0", Servers: []string{"1.1.1.1"}, Applied: &platform.Applied{Backend: "systemd-resolved", Servers: []string{"1.1.1.1"}}},
+		{Iface: "resolv.conf", Servers: []string{"127.0.0.53"}, Domains: []string{"lan"}},
+	}
+	id, err := SaveSnapshot(links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadSnapshot(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Applied == nil || got[0].Applied.Backend != "systemd-resolved" || got[1].Domains[0] != "lan" {
+		t.Errorf("Unexpected snapshot: %+v", got)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	before := []platform.Link{
+		{Iface: "eth0", Servers: []string{"192.168.1.1"}},
+		{Iface: "tun0", Servers: []string{"10.8.0.1"}, Domains: []string{"~corp.example"}},
+		{Iface: "wlan0", Servers: []string{"192.168.1.1"}},
+	}
+	after := []platform.Link{
+		{Iface: "eth0", Servers: []string{"1.1.1.1"}},
+		{Iface: "tun0", Servers: []string{"10.8.0.1"}, Domains: []string{"~corp.example"}},
+	}
+	var names []string
+	for _, l := range Changed(before, after) {
+		names = append(names, l.Iface)
+	}
+	if strings.Join(names, ",") != "eth0,wlan0" {
+		t.Errorf("Unexpected changed links: %v", names)
+	}
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	r := &Registry{}
+	c := r.Counter("queries_total", "Queries.", "server", "class")
+	g := r.Gauge("up", "Line one\nline two.")
+	h := r.Histogram("latency_seconds", "Latency.", []float64{0.01, 0.1}, "server")
+
+	c.Inc("1.1.1.1:53", "success")
+	c.Inc("1.1.1.1:53", "success")
+	c.Inc(`a"b\c`, "timeout")
+	g.Set(1)
+	h.Observe(0.005, "1.1.1.1:53")
+	h.Observe(0.05, "1.1.1.1:53")
+	h.Observe(3, "1.1.1.1:53")
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP queries_total Queries.
+# TYPE queries_total counter
+queries_total{server="1.1.1.1:53",class="success"} 2
+queries_total{server="a\"b\\c",class="timeout"} 1
+# HELP up Line one\nline two.
+# TYPE up gauge
+up 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{server="1.1.1.1:53",le="0.01"} 1
+latency_seconds_bucket{server="1.1.1.1:53",le="0.1"} 2
+latency_seconds_bucket{server="1.1.1.1:53",le="+Inf"} 3
+latency_seconds_sum{server="1.1.1.1:53"} 3.055
+latency_seconds_count{server="1.1.1.1:53"} 3
+`
+	if b.String() != expected {
+		t.Errorf("Unexpected exposition:\n%s\nexpected:\n%s", b.String(), expected)
+	}
+}
+
+func TestGaugeReset(t *testing.T) {
+	r := &Registry{}
+	g := r.Gauge("active", "Active.", "server")
+	g.Set(1, "1.1.1.1")
+	g.Reset()
+	g.Set(1, "8.8.8.8")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "1.1.1.1") || !strings.Contains(body, `active{server="8.8.8.8"} 1`) {
+		t.Errorf("Reset series must be gone:\n%s", body)
+	}
+}
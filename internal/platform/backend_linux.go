@@ -0,0 +1,121 @@
+//go:build linux
+
+package platform
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+// backend applies DNS settings to a single interface through one service.
+// Backends print the commands they would run when dryRun is set.
+type backend interface {
+	Name() string
+	Available() bool
+	Apply(iface string, s Settings, dryRun bool) error
+	Reset(iface string, dryRun bool) error
+	// Durability says what the changes made by Apply survive
+	Durability() Durability
+}
+
+// backends in order of preference
+var backends = []backend{
+	resolvedBus,
+	resolvectlBackend{run: util.ExecRunner{}},
+	nmBackend{run: util.ExecRunner{}},
+	resolvconfBackend{run: util.ExecRunner{}},
+}
+
+// modeBackends are every backend, persistent ones first; --persist and
+// --runtime pick from them by Durability
+var modeBackends = []backend{
+	netplanBackend{run: util.ExecRunner{}},
+	networkdBackend{run: util.ExecRunner{}},
+	nmBackend{run: util.ExecRunner{}},
+	resolvedBus,
+	resolvectlBackend{run: util.ExecRunner{}},
+	nmBackend{run: util.ExecRunner{}, device: true},
+	resolvconfBackend{run: util.ExecRunner{}},
+}
+
+// persister is implemented by persistent backends
+type persister interface {
+	// persisted reports whether dns-helper configuration for iface is on disk
+	persisted(iface string) bool
+}
+
+// runCmd runs a command, or prints it in dry-run mode. A failure is the
+// *util.CommandError, which carries the command and its stderr.
+func runCmd(run util.Runner, dryRun bool, timeout time.Duration, name string, args ...string) error {
+	if dryRun {
+		fmt.Printf("[DRY-RUN] Would run: %s\n", strings.Join(append([]string{name}, args...), " "))
+		return nil
+	}
+	return run.Run(timeout, name, args...).Err
+}
+
+// resolvectlBackend sets per-link DNS through the resolvectl CLI; it is
+// used when the system bus cannot be reached
+type resolvectlBackend struct {
+	run util.Runner
+}
+
+func (resolvectlBackend) Name() string { return "resolvectl" }
+
+func (resolvectlBackend) Available() bool { return resolvedActive() && !resolvedBus.Available() }
+
+// Durability: resolved forgets runtime link settings on restart, and
+// networkd pushes the lease's servers again on renewal
+func (resolvectlBackend) Durability() Durability { return runtimeOnly }
+
+func (b resolvectlBackend) Apply(iface string, s Settings, dryRun bool) error {
+	if err := runCmd(b.run, dryRun, 5*time.Second, "resolvectl", append([]string{"dns", iface}, s.Servers...)...); err != nil {
+		return err
+	}
+	warnResolvedOptions(s.Options)
+	if len(s.Search) == 0 {
+		return nil
+	}
+	var current []string
+	if links, err := (resolvedRoutes{run: b.run}).links(); err == nil {
+		current = linkDomains(links, iface)
+	}
+	return runCmd(b.run, dryRun, 5*time.Second, "resolvectl", append([]string{"domain", iface}, withRouting(s.Search, current)...)...)
+}
+
+// withRouting combines new search domains with the routing domains already
+// set on a link, so "route add" entries survive a switch
+func withRouting(search, current []string) []string {
+	out := append([]string(nil), search...)
+	for _, d := range current {
+		if strings.HasPrefix(d, "~") {
+			out = appendUnique(out, d)
+		}
+	}
+	return out
+}
+
+func linkDomains(links []Link, iface string) []string {
+	for _, l := range links {
+		if l.Iface == iface {
+			return l.Domains
+		}
+	}
+	return nil
+}
+
+// warnResolvedOptions notes that systemd-resolved has no per-link
+// equivalent of resolv.conf options; its stub file sets its own
+func warnResolvedOptions(opts []string) {
+	if len(opts) > 0 {
+		fmt.Printf("Note: systemd-resolved does not support resolver options; ignoring %v\n", opts)
+	}
+}
+
+func (b resolvectlBackend) Reset(iface string, dryRun bool) error {
+	// revert drops the runtime settings so the DHCP-provided servers apply again
+	return runCmd(b.run, dryRun, 5*time.Second, "resolvectl", "revert", iface)
+}
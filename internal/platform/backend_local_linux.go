@@ -0,0 +1,210 @@
+//go:build linux
+
+package platform
+
+// Local caching resolvers (dnsmasq, unbound). When resolv.conf points at
+// one of them, changing resolv.conf has no effect; instead only their
+// upstream forwarders are replaced, through a snippet dns-helper owns.
+// The snippet that was there before the first switch is kept in stateDir
+// and put back on reset.
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dns-helper/internal/resolvconf"
+	"dns-helper/internal/util"
+)
+
+// stateDir keeps dns-helper's saved state, replaced in tests
+var stateDir = "/var/lib/dns-helper"
+
+// localResolver manages the upstreams of one local caching resolver
+type localResolver struct {
+	name    string
+	snippet string   // path of the dns-helper snippet
+	service string   // systemd unit
+	check   []string // validates the configuration
+	reload  []string // makes the service pick up the snippet
+	render  func(servers []string) string
+	run     util.Runner
+}
+
+var localResolvers = []localResolver{
+	{
+		name:    "dnsmasq",
+		snippet: "/etc/dnsmasq.d/dns-helper.conf",
+		service: "dnsmasq",
+		check:   []string{"dnsmasq", "--test"},
+		// SIGHUP does not re-read the configuration, only a restart does
+		reload: []string{"systemctl", "restart", "dnsmasq"},
+		render: func(servers []string) string {
+			var b strings.Builder
+			b.WriteString("# Generated by dns-helper; restored by 'dns-helper reset'\n")
+			b.WriteString("# ignore the upstreams from resolv.conf\nno-resolv\n")
+			for _, s := range servers {
+				fmt.Fprintf(&b, "server=%s\n", s)
+			}
+			return b.String()
+		},
+		run: util.ExecRunner{},
+	},
+	{
+		name:    "unbound",
+		snippet: "/etc/unbound/unbound.conf.d/dns-helper.conf",
+		service: "unbound",
+		check:   []string{"unbound-checkconf"},
+		reload:  []string{"systemctl", "reload-or-restart", "unbound"},
+		render: func(servers []string) string {
+			var b strings.Builder
+			b.WriteString("# Generated by dns-helper; restored by 'dns-helper reset'\n")
+			b.WriteString("forward-zone:\n    name: \".\"\n")
+			for _, s := range servers {
+				fmt.Fprintf(&b, "    forward-addr: %s\n", s)
+			}
+			return b.String()
+		},
+		run: util.ExecRunner{},
+	},
+}
+
+// Available reports whether the service runs and reads the snippet directory
+func (l localResolver) Available() bool {
+	if fi, err := os.Stat(filepath.Dir(l.snippet)); err != nil || !fi.IsDir() {
+		return false
+	}
+	return l.run.Run(3*time.Second, "systemctl", "is-active", "--quiet", l.service).Err == nil
+}
+
+// Durability: the snippet is part of the service's configuration
+func (l localResolver) Durability() Durability { return persistent }
+
+func (l localResolver) backupPath() string {
+	return filepath.Join(stateDir, l.name+"-snippet.bak")
+}
+
+// Apply writes the upstream snippet. Search domains and options are
+// resolv.conf settings that the forwarders do not take.
+func (l localResolver) Apply(s Settings, dryRun bool) error {
+	if len(s.Search) > 0 || len(s.Options) > 0 {
+		fmt.Printf("Note: %s upstreams do not take search domains or options; ignoring them\n", l.name)
+	}
+	content := l.render(s.Servers)
+	old, err := os.ReadFile(l.snippet)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("[DRY-RUN] Would change %s:\n%s", l.snippet, util.Diff(l.snippet, l.snippet, string(old), content))
+		fmt.Printf("[DRY-RUN] Would run: %s && %s\n", strings.Join(l.check, " "), strings.Join(l.reload, " "))
+		return nil
+	}
+	// keep what was there before the first switch; an empty backup
+	// means there was no snippet
+	if !fileExists(l.backupPath()) {
+		if err := os.MkdirAll(stateDir, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(l.backupPath(), old, 0600); err != nil {
+			return fmt.Errorf("saving %s: %v", l.snippet, err)
+		}
+	}
+	return l.replace(old, []byte(content))
+}
+
+// Reset puts back the snippet saved by the first Apply
+func (l localResolver) Reset(dryRun bool) error {
+	saved, err := os.ReadFile(l.backupPath())
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("No saved %s configuration to restore\n", l.name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	old, err := os.ReadFile(l.snippet)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("[DRY-RUN] Would change %s:\n%s", l.snippet, util.Diff(l.snippet, l.snippet, string(old), string(saved)))
+		return nil
+	}
+	if err := l.replace(old, saved); err != nil {
+		return err
+	}
+	return os.Remove(l.backupPath())
+}
+
+// replace installs data as the snippet (removing it when empty), checks
+// the configuration and reloads the service. A rejected snippet is rolled
+// back to old.
+func (l localResolver) replace(old, data []byte) error {
+	write := func(b []byte) error {
+		if len(b) == 0 {
+			if err := os.Remove(l.snippet); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
+		return util.WriteAtomic(l.snippet, b, 0644)
+	}
+	if err := write(data); err != nil {
+		return err
+	}
+	if err := runCmd(l.run, false, 10*time.Second, l.check[0], l.check[1:]...); err != nil {
+		if rerr := write(old); rerr != nil {
+			return fmt.Errorf("%v; restoring %s: %v", err, l.snippet, rerr)
+		}
+		return err
+	}
+	return runCmd(l.run, false, 30*time.Second, l.reload[0], l.reload[1:]...)
+}
+
+// upstreams returns the servers in the dns-helper snippet
+func (l localResolver) upstreams() []string {
+	data, err := os.ReadFile(l.snippet)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		for _, prefix := range []string{"server=", "forward-addr:"} {
+			if strings.HasPrefix(line, prefix) {
+				out = append(out, strings.TrimSpace(strings.TrimPrefix(line, prefix)))
+			}
+		}
+	}
+	return out
+}
+
+// activeLocalResolver returns the local resolver resolv.conf points at, if
+// any. The systemd-resolved stub (127.0.0.53) does not count.
+func activeLocalResolver() (localResolver, bool) {
+	f, err := resolvconf.Read(resolvConfPath)
+	if err != nil {
+		return localResolver{}, false
+	}
+	servers := f.Nameservers()
+	if len(servers) == 0 {
+		return localResolver{}, false
+	}
+	for _, s := range servers {
+		ip := net.ParseIP(s)
+		if ip == nil || !ip.IsLoopback() || s == "127.0.0.53" || s == "127.0.0.54" {
+			return localResolver{}, false
+		}
+	}
+	for _, l := range localResolvers {
+		if l.Available() {
+			return l, true
+		}
+	}
+	return localResolver{}, false
+}
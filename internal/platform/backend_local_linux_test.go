@@ -0,0 +1,111 @@
+//go:build linux
+
+package platform
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dns-helper/internal/util"
+)
+
+func newLocal(t *testing.T, name string) (localResolver, *fakeRunner) {
+	dir := t.TempDir()
+	orig := stateDir
+	stateDir = filepath.Join(dir, "state")
+	t.Cleanup(func() { stateDir = orig })
+	var l localResolver
+	for _, r := range localResolvers {
+		if r.name == name {
+			l = r
+		}
+	}
+	f := &fakeRunner{outputs: map[string]util.CmdResult{}}
+	l.snippet = filepath.Join(dir, "dns-helper.conf")
+	l.run = f
+	return l, f
+}
+
+func TestDnsmasqApplyAndReset(t *testing.T) {
+	l, f := newLocal(t, "dnsmasq")
+	if err := l.Apply(Settings{Servers: []string{"1.1.1.1", "1.0.0.1"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(l.snippet)
+	if !strings.Contains(string(data), "no-resolv\nserver=1.1.1.1\nserver=1.0.0.1\n") {
+		t.Errorf("Unexpected snippet:\n%s", data)
+	}
+	if got := strings.Join(f.calls, ";"); got != "dnsmasq --test;systemctl restart dnsmasq" {
+		t.Errorf("Unexpected commands: %s", got)
+	}
+	if got := l.upstreams(); strings.Join(got, ",") != "1.1.1.1,1.0.0.1" {
+		t.Errorf("upstreams() = %v", got)
+	}
+
+	// a second switch keeps the original backup
+	if err := l.Apply(Settings{Servers: []string{"9.9.9.9"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reset(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(l.snippet); !os.IsNotExist(err) {
+		t.Error("Expected the snippet to be removed, as there was none before")
+	}
+	if _, err := os.Stat(l.backupPath()); !os.IsNotExist(err) {
+		t.Error("Expected the backup to be removed")
+	}
+}
+
+func TestUnboundRestoresPreviousSnippet(t *testing.T) {
+	l, _ := newLocal(t, "unbound")
+	previous := "forward-zone:\n    name: \".\"\n    forward-addr: 192.168.1.1\n"
+	if err := os.WriteFile(l.snippet, []byte(previous), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Apply(Settings{Servers: []string{"9.9.9.9"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(l.snippet)
+	if !strings.Contains(string(data), "    forward-addr: 9.9.9.9\n") {
+		t.Errorf("Unexpected snippet:\n%s", data)
+	}
+	if err := l.Reset(false); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(l.snippet); string(data) != previous {
+		t.Errorf("Expected the previous snippet back, got:\n%s", data)
+	}
+}
+
+func TestLocalResolverRollsBackRejectedConfig(t *testing.T) {
+	l, f := newLocal(t, "unbound")
+	f.outputs["unbound-checkconf"] = util.CmdResult{Err: errors.New("exit status 1"), Stderr: "duplicate forward zone . ignored."}
+	if err := l.Apply(Settings{Servers: []string{"9.9.9.9"}}, false); err == nil {
+		t.Fatal("Expected the check error")
+	}
+	if _, err := os.Stat(l.snippet); !os.IsNotExist(err) {
+		t.Error("Expected the rejected snippet to be removed")
+	}
+	if len(f.ran("systemctl")) != 0 {
+		t.Error("The service must not be reloaded with a rejected config")
+	}
+}
+
+func TestActiveLocalResolverSkipsResolvedStub(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "resolv.conf")
+	orig := resolvConfPath
+	resolvConfPath = p
+	t.Cleanup(func() { resolvConfPath = orig })
+	os.WriteFile(p, []byte("nameserver 127.0.0.53\n"), 0644)
+	if _, ok := activeLocalResolver(); ok {
+		t.Error("The systemd-resolved stub is not a local cache")
+	}
+	os.WriteFile(p, []byte("nameserver 192.168.1.1\n"), 0644)
+	if _, ok := activeLocalResolver(); ok {
+		t.Error("A remote server is not a local cache")
+	}
+}
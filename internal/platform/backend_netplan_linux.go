@@ -0,0 +1,305 @@
+//go:build linux
+
+package platform
+
+// Persistent netplan backend. The administrator's YAML is only read; DNS
+// settings go into a separate override file that sorts after it, so
+// "netplan apply" keeps them and reset simply drops our entries.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"dns-helper/internal/util"
+
+	"gopkg.in/yaml.v3"
+)
+
+// netplanDir holds the netplan configuration, replaced in tests
+var netplanDir = "/etc/netplan"
+
+const netplanOverride = "90-dns-helper.yaml"
+
+type netplanBackend struct {
+	run util.Runner
+}
+
+// netplanDevice is the part of an ethernets/wifis entry used to find the link
+type netplanDevice struct {
+	DHCP4   bool   `yaml:"dhcp4"`
+	DHCP6   bool   `yaml:"dhcp6"`
+	SetName string `yaml:"set-name"`
+	Match   struct {
+		Name string `yaml:"name"`
+	} `yaml:"match"`
+}
+
+type netplanConfig struct {
+	Network struct {
+		Ethernets map[string]netplanDevice `yaml:"ethernets"`
+		Wifis     map[string]netplanDevice `yaml:"wifis"`
+	} `yaml:"network"`
+}
+
+type netplanNameservers struct {
+	Addresses []string `yaml:"addresses,omitempty"`
+	Search    []string `yaml:"search,omitempty"`
+}
+
+type netplanDHCPOverrides struct {
+	UseDNS     bool  `yaml:"use-dns"`
+	UseDomains *bool `yaml:"use-domains,omitempty"`
+}
+
+// overrideDevice is the entry dns-helper writes for one link
+type overrideDevice struct {
+	Nameservers    netplanNameservers    `yaml:"nameservers"`
+	DHCP4Overrides *netplanDHCPOverrides `yaml:"dhcp4-overrides,omitempty"`
+	DHCP6Overrides *netplanDHCPOverrides `yaml:"dhcp6-overrides,omitempty"`
+}
+
+type overrideConfig struct {
+	Network struct {
+		Version   int                       `yaml:"version"`
+		Ethernets map[string]overrideDevice `yaml:"ethernets,omitempty"`
+		Wifis     map[string]overrideDevice `yaml:"wifis,omitempty"`
+	} `yaml:"network"`
+}
+
+func (netplanBackend) Name() string { return "netplan" }
+
+func (netplanBackend) Durability() Durability { return persistent }
+
+func (netplanBackend) Available() bool {
+	if !fileExists("/usr/sbin/netplan") && !fileExists("/sbin/netplan") {
+		return false
+	}
+	files, _ := netplanFiles()
+	return len(files) > 0
+}
+
+// netplanFiles lists the administrator's YAML files, without our override
+func netplanFiles() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(netplanDir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, f := range files {
+		if filepath.Base(f) != netplanOverride {
+			out = append(out, f)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// findDevice returns the section ("ethernets" or "wifis") and id of the
+// entry that configures iface. An id or set-name equal to iface wins over a
+// match.name glob; otherwise files, sections and ids are tried in sorted
+// order, so the same entry is found every time.
+func findDevice(iface string) (string, string, netplanDevice, error) {
+	files, err := netplanFiles()
+	if err != nil {
+		return "", "", netplanDevice{}, err
+	}
+	type entry struct {
+		section, id string
+		dev         netplanDevice
+	}
+	var glob *entry
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", "", netplanDevice{}, err
+		}
+		var cfg netplanConfig
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return "", "", netplanDevice{}, fmt.Errorf("%s: %v", file, err)
+		}
+		for _, section := range []string{"ethernets", "wifis"} {
+			devs := cfg.Network.Ethernets
+			if section == "wifis" {
+				devs = cfg.Network.Wifis
+			}
+			ids := make([]string, 0, len(devs))
+			for id := range devs {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			for _, id := range ids {
+				d := devs[id]
+				if id == iface || d.SetName == iface {
+					return section, id, d, nil
+				}
+				if ok, _ := filepath.Match(d.Match.Name, iface); d.Match.Name != "" && ok && glob == nil {
+					glob = &entry{section, id, d}
+				}
+			}
+		}
+	}
+	if glob != nil {
+		return glob.section, glob.id, glob.dev, nil
+	}
+	return "", "", netplanDevice{}, fmt.Errorf("no ethernets or wifis entry for %s in %s", iface, netplanDir)
+}
+
+func overridePath() string {
+	return filepath.Join(netplanDir, netplanOverride)
+}
+
+func readOverride() (overrideConfig, []byte, error) {
+	var cfg overrideConfig
+	data, err := os.ReadFile(overridePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil, nil
+	}
+	if err != nil {
+		return cfg, nil, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, nil, fmt.Errorf("%s: %v", overridePath(), err)
+	}
+	return cfg, data, nil
+}
+
+func renderOverride(cfg overrideConfig) ([]byte, error) {
+	cfg.Network.Version = 2
+	var b bytes.Buffer
+	b.WriteString("# Generated by dns-helper; entries are removed by 'dns-helper reset --persist'\n")
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2) // as in netplan's own examples
+	if err := enc.Encode(cfg); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (b netplanBackend) Apply(iface string, s Settings, dryRun bool) error {
+	section, id, dev, err := findDevice(iface)
+	if err != nil {
+		return err
+	}
+	if len(s.Options) > 0 {
+		fmt.Printf("Note: netplan does not support resolver options; ignoring %v\n", s.Options)
+	}
+	cfg, old, err := readOverride()
+	if err != nil {
+		return err
+	}
+	entry := overrideDevice{Nameservers: netplanNameservers{Addresses: s.Servers, Search: s.Search}}
+	// keep DHCP-provided servers (and domains, when we set our own) out
+	overrides := &netplanDHCPOverrides{}
+	if len(s.Search) > 0 {
+		no := false
+		overrides.UseDomains = &no
+	}
+	if dev.DHCP4 {
+		entry.DHCP4Overrides = overrides
+	}
+	if dev.DHCP6 {
+		entry.DHCP6Overrides = overrides
+	}
+	devs := cfg.Network.Ethernets
+	if section == "wifis" {
+		devs = cfg.Network.Wifis
+	}
+	if devs == nil {
+		devs = map[string]overrideDevice{}
+	}
+	devs[id] = entry
+	if section == "wifis" {
+		cfg.Network.Wifis = devs
+	} else {
+		cfg.Network.Ethernets = devs
+	}
+	data, err := renderOverride(cfg)
+	if err != nil {
+		return err
+	}
+	return b.write(old, data, dryRun)
+}
+
+func (b netplanBackend) Reset(iface string, dryRun bool) error {
+	_, id, _, err := findDevice(iface)
+	if err != nil {
+		return err
+	}
+	cfg, old, err := readOverride()
+	if err != nil {
+		return err
+	}
+	_, inEth := cfg.Network.Ethernets[id]
+	_, inWifi := cfg.Network.Wifis[id]
+	if !inEth && !inWifi {
+		fmt.Printf("No dns-helper netplan entry for %s\n", iface)
+		return nil
+	}
+	delete(cfg.Network.Ethernets, id)
+	delete(cfg.Network.Wifis, id)
+	var data []byte
+	if len(cfg.Network.Ethernets)+len(cfg.Network.Wifis) > 0 {
+		if data, err = renderOverride(cfg); err != nil {
+			return err
+		}
+	}
+	return b.write(old, data, dryRun)
+}
+
+// persisted reports whether the override has an entry for iface
+func (netplanBackend) persisted(iface string) bool {
+	_, id, _, err := findDevice(iface)
+	if err != nil {
+		return false
+	}
+	cfg, _, err := readOverride()
+	if err != nil {
+		return false
+	}
+	_, inEth := cfg.Network.Ethernets[id]
+	_, inWifi := cfg.Network.Wifis[id]
+	return inEth || inWifi
+}
+
+// write replaces the override (removing it when data is nil), validates
+// it with "netplan generate" and applies it. A configuration netplan
+// rejects is rolled back.
+func (b netplanBackend) write(old, data []byte, dryRun bool) error {
+	path := overridePath()
+	if dryRun {
+		if d := util.Diff(path, path, string(old), string(data)); d != "" {
+			fmt.Printf("[DRY-RUN] Would change %s:\n%s", path, d)
+		}
+		fmt.Println("[DRY-RUN] Would run: netplan generate && netplan apply")
+		return nil
+	}
+	if err := b.replace(path, data); err != nil {
+		return err
+	}
+	if err := runCmd(b.run, false, 30*time.Second, "netplan", "generate"); err != nil {
+		if rerr := b.replace(path, old); rerr != nil {
+			return fmt.Errorf("%v; restoring %s: %v", err, path, rerr)
+		}
+		return err
+	}
+	return runCmd(b.run, false, 60*time.Second, "netplan", "apply")
+}
+
+func (b netplanBackend) replace(path string, data []byte) error {
+	if data == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	// netplan warns about configuration readable by other users
+	return util.WriteAtomic(path, data, 0600)
+}
@@ -0,0 +1,149 @@
+//go:build linux
+
+package platform
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dns-helper/internal/util"
+)
+
+const netplanBase = `network:
+  version: 2
+  ethernets:
+    eth0:
+      dhcp4: true
+    lan:
+      match:
+        name: enp*
+      set-name: lan0
+      addresses: [10.0.0.5/24]
+  wifis:
+    wlp3s0:
+      dhcp4: yes
+      access-points:
+        home: {password: secret}
+`
+
+func newNetplan(t *testing.T) (netplanBackend, *fakeRunner) {
+	dir := t.TempDir()
+	orig := netplanDir
+	netplanDir = dir
+	t.Cleanup(func() { netplanDir = orig })
+	if err := os.WriteFile(filepath.Join(dir, "50-cloud-init.yaml"), []byte(netplanBase), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRunner{outputs: map[string]util.CmdResult{}}
+	return netplanBackend{run: f}, f
+}
+
+func TestFindDevice(t *testing.T) {
+	newNetplan(t)
+	for iface, want := range map[string]string{"eth0": "ethernets/eth0", "lan0": "ethernets/lan", "enp3s0": "ethernets/lan", "wlp3s0": "wifis/wlp3s0"} {
+		section, id, _, err := findDevice(iface)
+		if err != nil || section+"/"+id != want {
+			t.Errorf("findDevice(%s) = %s/%s, %v; want %s", iface, section, id, err, want)
+		}
+	}
+	if _, _, _, err := findDevice("tun0"); err == nil {
+		t.Error("Expected an error for an unconfigured link")
+	}
+}
+
+func TestFindDevicePrefersExactMatch(t *testing.T) {
+	newNetplan(t)
+	// "any" sorts before "enp3s0" and its glob matches as well
+	extra := `network:
+  version: 2
+  ethernets:
+    any:
+      match:
+        name: enp*
+    enp3s0:
+      dhcp4: true
+`
+	if err := os.WriteFile(filepath.Join(netplanDir, "60-extra.yaml"), []byte(extra), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		section, id, _, err := findDevice("enp3s0")
+		if err != nil || section+"/"+id != "ethernets/enp3s0" {
+			t.Fatalf("findDevice(enp3s0) = %s/%s, %v; want the exact entry", section, id, err)
+		}
+		// without an exact entry the first glob in file and id order wins
+		if section, id, _, _ := findDevice("enp4s0"); section+"/"+id != "ethernets/lan" {
+			t.Fatalf("findDevice(enp4s0) = %s/%s; want ethernets/lan", section, id)
+		}
+	}
+}
+
+func TestNetplanApplyAndReset(t *testing.T) {
+	b, f := newNetplan(t)
+	s := Settings{Servers: []string{"1.1.1.1", "1.0.0.1"}, Search: []string{"corp.example"}}
+	if err := b.Apply("eth0", s, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Apply("wlp3s0", Settings{Servers: []string{"9.9.9.9"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(overridePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"    eth0:\n      nameservers:\n        addresses:\n          - 1.1.1.1\n          - 1.0.0.1\n        search:\n          - corp.example\n      dhcp4-overrides:\n        use-dns: false\n        use-domains: false\n",
+		"    wlp3s0:\n      nameservers:\n        addresses:\n          - 9.9.9.9\n      dhcp4-overrides:\n        use-dns: false\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("override lacks:\n%s\ngot:\n%s", want, data)
+		}
+	}
+	if got := f.ran("netplan"); strings.Join(got, ";") != "netplan generate;netplan apply;netplan generate;netplan apply" {
+		t.Errorf("Unexpected commands: %v", got)
+	}
+	base, _ := os.ReadFile(filepath.Join(netplanDir, "50-cloud-init.yaml"))
+	if string(base) != netplanBase {
+		t.Error("The administrator's file was modified")
+	}
+
+	if err := b.Reset("eth0", false); err != nil {
+		t.Fatal(err)
+	}
+	if b.persisted("eth0") || !b.persisted("wlp3s0") {
+		t.Error("Reset must remove only the eth0 entry")
+	}
+	if err := b.Reset("wlp3s0", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(overridePath()); !os.IsNotExist(err) {
+		t.Error("Expected the empty override to be removed")
+	}
+}
+
+func TestNetplanRollsBackOnGenerateError(t *testing.T) {
+	b, f := newNetplan(t)
+	f.outputs["netplan generate"] = util.CmdResult{Err: errors.New("exit status 1"), Stderr: "Error in network definition"}
+	if err := b.Apply("eth0", Settings{Servers: []string{"1.1.1.1"}}, false); err == nil {
+		t.Fatal("Expected the generate error")
+	}
+	if _, err := os.Stat(overridePath()); !os.IsNotExist(err) {
+		t.Error("Expected the rejected override to be rolled back")
+	}
+	if len(f.ran("netplan apply")) != 0 {
+		t.Error("apply must not run after a failed generate")
+	}
+}
+
+func TestNetplanDryRunLeavesFiles(t *testing.T) {
+	b, _ := newNetplan(t)
+	if err := b.Apply("eth0", Settings{Servers: []string{"1.1.1.1"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(overridePath()); !os.IsNotExist(err) {
+		t.Error("Dry run wrote the override")
+	}
+}
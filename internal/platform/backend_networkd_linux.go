@@ -0,0 +1,143 @@
+//go:build linux
+
+package platform
+
+// Persistent systemd-networkd backend. Settings go into a drop-in next to
+// the .network file that manages the link, so they survive reboots and
+// lease renewals, unlike runtime changes made through resolved.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+// networkdDir holds the drop-in directories, replaced in tests
+var networkdDir = "/etc/systemd/network"
+
+const dropInName = "50-dns-helper.conf"
+
+type networkdBackend struct {
+	run util.Runner
+}
+
+func (networkdBackend) Name() string { return "systemd-networkd" }
+
+func (networkdBackend) Durability() Durability { return persistent }
+
+func (networkdBackend) Available() bool {
+	return fileExists("/run/systemd/netif/state") && (fileExists("/usr/bin/networkctl") || fileExists("/bin/networkctl"))
+}
+
+// networkFile returns the .network file networkd applied to iface
+func (b networkdBackend) networkFile(iface string) (string, error) {
+	out := b.run.Run(5*time.Second, "networkctl", "status", "--no-pager", iface)
+	if out.Err != nil {
+		return "", out.Err
+	}
+	for _, l := range strings.Split(out.Stdout, "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(l), ":")
+		if ok && k == "Network File" {
+			if v = strings.TrimSpace(v); v != "" && v != "n/a" {
+				return v, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%s is not managed by systemd-networkd", iface)
+}
+
+// dropInPath is the dns-helper drop-in for the .network file of iface
+func (b networkdBackend) dropInPath(iface string) (string, error) {
+	file, err := b.networkFile(iface)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(networkdDir, filepath.Base(file)+".d", dropInName), nil
+}
+
+// dropIn renders the drop-in. UseDNS=no keeps DHCP and router
+// advertisement servers from being added next to ours.
+func dropIn(s Settings) string {
+	var b strings.Builder
+	b.WriteString("# Generated by dns-helper; removed by 'dns-helper reset --persist'\n[Network]\n")
+	for _, server := range s.Servers {
+		fmt.Fprintf(&b, "DNS=%s\n", server)
+	}
+	if len(s.Search) > 0 {
+		fmt.Fprintf(&b, "Domains=%s\n", strings.Join(s.Search, " "))
+	}
+	for _, section := range []string{"DHCPv4", "DHCPv6", "IPv6AcceptRA"} {
+		fmt.Fprintf(&b, "\n[%s]\nUseDNS=no\n", section)
+		if len(s.Search) > 0 {
+			b.WriteString("UseDomains=no\n")
+		}
+	}
+	return b.String()
+}
+
+func (b networkdBackend) Apply(iface string, s Settings, dryRun bool) error {
+	path, err := b.dropInPath(iface)
+	if err != nil {
+		return err
+	}
+	if len(s.Options) > 0 {
+		fmt.Printf("Note: systemd-networkd does not support resolver options; ignoring %v\n", s.Options)
+	}
+	content := dropIn(s)
+	if dryRun {
+		fmt.Printf("[DRY-RUN] Would write %s:\n%s", path, content)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := util.WriteAtomic(path, []byte(content), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+	return b.reload(iface, dryRun)
+}
+
+// Reset deletes the dns-helper drop-in of iface and nothing else
+func (b networkdBackend) Reset(iface string, dryRun bool) error {
+	path, err := b.dropInPath(iface)
+	if err != nil {
+		return err
+	}
+	if !fileExists(path) {
+		fmt.Printf("No dns-helper drop-in for %s\n", iface)
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("[DRY-RUN] Would remove %s\n", path)
+	} else {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s\n", path)
+		// the directory may hold drop-ins of other tools
+		if err := os.Remove(filepath.Dir(path)); err != nil && !errors.Is(err, syscall.ENOTEMPTY) {
+			return err
+		}
+	}
+	return b.reload(iface, dryRun)
+}
+
+// persisted reports whether a drop-in exists for iface
+func (b networkdBackend) persisted(iface string) bool {
+	path, err := b.dropInPath(iface)
+	return err == nil && fileExists(path)
+}
+
+func (b networkdBackend) reload(iface string, dryRun bool) error {
+	if err := runCmd(b.run, dryRun, 10*time.Second, "networkctl", "reload"); err != nil {
+		return err
+	}
+	return runCmd(b.run, dryRun, 10*time.Second, "networkctl", "reconfigure", iface)
+}
@@ -0,0 +1,102 @@
+//go:build linux
+
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dns-helper/internal/util"
+)
+
+const networkctlStatus = `● 2: eth0
+                     Link File: /usr/lib/systemd/network/99-default.link
+                  Network File: /etc/systemd/network/20-wired.network
+                         State: routable (configured)`
+
+func newNetworkd(t *testing.T) (networkdBackend, *fakeRunner, string) {
+	dir := t.TempDir()
+	orig := networkdDir
+	networkdDir = dir
+	t.Cleanup(func() { networkdDir = orig })
+	f := &fakeRunner{outputs: map[string]util.CmdResult{
+		"networkctl status --no-pager eth0":  {Stdout: networkctlStatus},
+		"networkctl status --no-pager wlan0": {Stdout: "● 3: wlan0\n  Network File: n/a"},
+	}}
+	return networkdBackend{run: f}, f, filepath.Join(dir, "20-wired.network.d", dropInName)
+}
+
+func TestNetworkdApplyWritesDropIn(t *testing.T) {
+	b, f, path := newNetworkd(t)
+	s := Settings{Servers: []string{"1.1.1.1", "2606:4700:4700::1111"}, Search: []string{"corp.example"}}
+	if err := b.Apply("eth0", s, false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"[Network]\nDNS=1.1.1.1\nDNS=2606:4700:4700::1111\nDomains=corp.example\n", "[DHCPv4]\nUseDNS=no\nUseDomains=no\n", "[IPv6AcceptRA]\nUseDNS=no\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("drop-in lacks %q:\n%s", want, data)
+		}
+	}
+	if got := f.ran("networkctl re"); strings.Join(got, ";") != "networkctl reload;networkctl reconfigure eth0" {
+		t.Errorf("Unexpected reload commands: %v", got)
+	}
+}
+
+func TestNetworkdResetRemovesOnlyOwnDropIn(t *testing.T) {
+	b, _, path := newNetworkd(t)
+	if err := b.Apply("eth0", Settings{Servers: []string{"9.9.9.9"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(filepath.Dir(path), "10-mtu.conf")
+	if err := os.WriteFile(other, []byte("[Link]\nMTUBytes=1400\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !b.persisted("eth0") {
+		t.Fatal("Expected the drop-in to exist")
+	}
+	if err := b.Reset("eth0", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the dns-helper drop-in to be removed")
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("Another drop-in was removed: %v", err)
+	}
+
+	// with nothing left, the directory goes too
+	os.Remove(other)
+	b.Apply("eth0", Settings{Servers: []string{"9.9.9.9"}}, false)
+	if err := b.Reset("eth0", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Error("Expected the empty drop-in directory to be removed")
+	}
+}
+
+func TestNetworkdUnmanagedLink(t *testing.T) {
+	b, _, _ := newNetworkd(t)
+	if err := b.Apply("wlan0", Settings{Servers: []string{"9.9.9.9"}}, false); err == nil {
+		t.Error("Expected an error for a link networkd does not manage")
+	}
+}
+
+func TestNetworkdDryRun(t *testing.T) {
+	b, f, path := newNetworkd(t)
+	if err := b.Apply("eth0", Settings{Servers: []string{"9.9.9.9"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Dry run wrote the drop-in")
+	}
+	if len(f.ran("networkctl reload")) != 0 {
+		t.Error("Dry run reloaded networkd")
+	}
+}
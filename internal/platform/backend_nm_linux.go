@@ -0,0 +1,151 @@
+//go:build linux
+
+package platform
+
+// NetworkManager backend. nmcli addresses connection profiles, not
+// devices, so the device is first mapped to the UUID of its active
+// connection. The device variant changes only the settings applied to the
+// device, which last until it reconnects.
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+type nmBackend struct {
+	run    util.Runner
+	device bool // "nmcli device modify" instead of changing the profile
+}
+
+func (b nmBackend) Name() string {
+	if b.device {
+		return "NetworkManager (device)"
+	}
+	return "NetworkManager"
+}
+
+// Durability: ignore-auto-dns keeps lease renewals from replacing the
+// servers; only a profile change survives the device reconnecting
+func (b nmBackend) Durability() Durability {
+	if b.device {
+		return untilReboot
+	}
+	return persistent
+}
+
+func (b nmBackend) Available() bool {
+	if !nmAvailable() {
+		return false
+	}
+	out := b.run.Run(3*time.Second, "nmcli", "-t", "-f", "RUNNING", "general")
+	return out.Err == nil && strings.TrimSpace(out.Stdout) == "running"
+}
+
+// connectionUUID returns the UUID of the connection active on iface
+func (b nmBackend) connectionUUID(iface string) (string, error) {
+	out := b.run.Run(5*time.Second, "nmcli", "-t", "-f", "DEVICE,UUID", "connection", "show", "--active")
+	if out.Err != nil {
+		return "", out.Err
+	}
+	for _, l := range strings.Split(out.Stdout, "\n") {
+		f := splitTerse(l)
+		if len(f) == 2 && f[0] == iface {
+			return f[1], nil
+		}
+	}
+	return "", fmt.Errorf("no active NetworkManager connection on %s", iface)
+}
+
+func (b nmBackend) Apply(iface string, s Settings, dryRun bool) error {
+	var args []string
+	if b.device {
+		args = []string{"device", "modify", iface}
+	} else {
+		uuid, err := b.connectionUUID(iface)
+		if err != nil {
+			return err
+		}
+		args = []string{"connection", "modify", uuid}
+	}
+	v4, v6 := splitFamilies(s.Servers)
+	// ignore-auto-dns keeps DHCP/RA servers from being merged back in
+	args = append(args,
+		"ipv4.dns", strings.Join(v4, ","), "ipv4.ignore-auto-dns", "yes",
+		"ipv6.dns", strings.Join(v6, ","), "ipv6.ignore-auto-dns", "yes")
+	if len(s.Search) > 0 {
+		search := strings.Join(s.Search, ",")
+		args = append(args, "ipv4.dns-search", search, "ipv6.dns-search", search)
+	}
+	if len(s.Options) > 0 {
+		opts := strings.Join(s.Options, ",")
+		args = append(args, "ipv4.dns-options", opts, "ipv6.dns-options", opts)
+	}
+	if err := runCmd(b.run, dryRun, 8*time.Second, "nmcli", args...); err != nil {
+		return err
+	}
+	if b.device {
+		return nil // device modify takes effect immediately
+	}
+	return b.reapply(iface, dryRun)
+}
+
+func (b nmBackend) Reset(iface string, dryRun bool) error {
+	if b.device {
+		// reapplying the profile drops the device-only changes
+		return b.reapply(iface, dryRun)
+	}
+	uuid, err := b.connectionUUID(iface)
+	if err != nil {
+		return err
+	}
+	err = runCmd(b.run, dryRun, 8*time.Second, "nmcli", "connection", "modify", uuid,
+		"ipv4.dns", "", "ipv4.ignore-auto-dns", "no", "ipv4.dns-search", "", "ipv4.dns-options", "",
+		"ipv6.dns", "", "ipv6.ignore-auto-dns", "no", "ipv6.dns-search", "", "ipv6.dns-options", "")
+	if err != nil {
+		return err
+	}
+	return b.reapply(iface, dryRun)
+}
+
+// reapply pushes the modified profile to the device without bouncing it
+func (b nmBackend) reapply(iface string, dryRun bool) error {
+	return runCmd(b.run, dryRun, 10*time.Second, "nmcli", "device", "reapply", iface)
+}
+
+// splitTerse splits a line of "nmcli -t" output on unescaped colons
+func splitTerse(line string) []string {
+	if line == "" {
+		return nil
+	}
+	var fields []string
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			cur.WriteByte(line[i])
+		case c == ':':
+			fields = append(fields, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(fields, cur.String())
+}
+
+// splitFamilies separates IPv4 and IPv6 server addresses
+func splitFamilies(servers []string) (v4, v6 []string) {
+	for _, s := range servers {
+		if ip := net.ParseIP(s); ip != nil && ip.To4() == nil {
+			v6 = append(v6, s)
+		} else {
+			v4 = append(v4, s)
+		}
+	}
+	return v4, v6
+}
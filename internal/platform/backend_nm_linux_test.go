@@ -0,0 +1,137 @@
+//go:build linux
+
+package platform
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"dns-helper/internal/util"
+)
+
+const nmActive = `lo:4a3f6c2e-0000-0000-0000-000000000001
+wlp3s0:9f1c8a52-3a0e-4d7e-9b57-1f1e7c2d9a10
+tun0:1b2c3d4e-5f60-7182-93a4-b5c6d7e8f901`
+
+func newNMRunner() *fakeRunner {
+	return &fakeRunner{outputs: map[string]util.CmdResult{
+		"nmcli -t -f DEVICE,UUID connection show --active": {Stdout: nmActive},
+	}}
+}
+
+func TestSplitTerse(t *testing.T) {
+	got := splitTerse(`Wired\: office:eth0:a\\b`)
+	if len(got) != 3 || got[0] != "Wired: office" || got[1] != "eth0" || got[2] != `a\b` {
+		t.Errorf("Unexpected fields: %q", got)
+	}
+}
+
+func TestNMApplyUsesConnectionUUID(t *testing.T) {
+	f := newNMRunner()
+	b := nmBackend{run: f}
+	if err := b.Apply("wlp3s0", Settings{Servers: []string{"1.1.1.1", "2606:4700:4700::1111", "1.0.0.1"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"nmcli connection modify 9f1c8a52-3a0e-4d7e-9b57-1f1e7c2d9a10 ipv4.dns 1.1.1.1,1.0.0.1 ipv4.ignore-auto-dns yes ipv6.dns 2606:4700:4700::1111 ipv6.ignore-auto-dns yes",
+		"nmcli device reapply wlp3s0",
+	}
+	got := f.ran("nmcli connection modify")
+	got = append(got, f.ran("nmcli device")...)
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected commands:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+	if len(f.ran("nmcli con up")) != 0 {
+		t.Error("The connection must not be bounced")
+	}
+}
+
+// servers as given on the command line, ports and brackets included
+func TestNMApplyMixedFamiliesFromUserInput(t *testing.T) {
+	f := newNMRunner()
+	b := nmBackend{run: f}
+	servers := stripPorts([]string{"9.9.9.9:53", "2620:fe::fe", "[2620:fe::9]:53", "149.112.112.112"})
+	if err := b.Apply("wlp3s0", Settings{Servers: servers}, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := "nmcli connection modify 9f1c8a52-3a0e-4d7e-9b57-1f1e7c2d9a10 ipv4.dns 9.9.9.9,149.112.112.112 ipv4.ignore-auto-dns yes ipv6.dns 2620:fe::fe,2620:fe::9 ipv6.ignore-auto-dns yes"
+	if got := f.ran("nmcli connection modify"); len(got) != 1 || got[0] != expected {
+		t.Errorf("Unexpected commands:\n%s\nexpected:\n%s", strings.Join(got, "\n"), expected)
+	}
+}
+
+func TestNMApplySearchAndOptions(t *testing.T) {
+	f := newNMRunner()
+	b := nmBackend{run: f}
+	s := Settings{Servers: []string{"10.96.0.10"}, Search: []string{"svc.cluster.local", "cluster.local"}, Options: []string{"ndots:5", "rotate"}}
+	if err := b.Apply("wlp3s0", s, false); err != nil {
+		t.Fatal(err)
+	}
+	got := f.ran("nmcli connection modify")
+	if len(got) != 1 ||
+		!strings.Contains(got[0], "ipv4.dns-search svc.cluster.local,cluster.local ipv6.dns-search svc.cluster.local,cluster.local") ||
+		!strings.Contains(got[0], "ipv4.dns-options ndots:5,rotate ipv6.dns-options ndots:5,rotate") {
+		t.Errorf("Unexpected command: %v", got)
+	}
+}
+
+func TestNMReset(t *testing.T) {
+	f := newNMRunner()
+	b := nmBackend{run: f}
+	if err := b.Reset("tun0", false); err != nil {
+		t.Fatal(err)
+	}
+	got := f.ran("nmcli connection modify")
+	if len(got) != 1 || !strings.Contains(got[0], "1b2c3d4e-5f60-7182-93a4-b5c6d7e8f901 ipv4.dns  ipv4.ignore-auto-dns no") {
+		t.Errorf("Unexpected reset command: %v", got)
+	}
+}
+
+func TestNMUnknownDevice(t *testing.T) {
+	b := nmBackend{run: newNMRunner()}
+	if err := b.Apply("eth9", Settings{Servers: []string{"1.1.1.1"}}, false); err == nil {
+		t.Error("Expected error for a device without an active connection")
+	}
+}
+
+func TestNMReportsCommandErrors(t *testing.T) {
+	f := newNMRunner()
+	f.outputs["nmcli device reapply wlp3s0"] = util.CmdResult{Err: errors.New("exit status 10"), Stderr: "Error: Device 'wlp3s0' not found."}
+	b := nmBackend{run: f}
+	err := b.Apply("wlp3s0", Settings{Servers: []string{"1.1.1.1"}}, false)
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected stderr in error, got %v", err)
+	}
+}
+
+func TestNMDryRunOnlyReads(t *testing.T) {
+	f := newNMRunner()
+	b := nmBackend{run: f}
+	if err := b.Apply("wlp3s0", Settings{Servers: []string{"1.1.1.1"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("Dry run should only look up the connection, ran %v", f.calls)
+	}
+}
+
+func TestNMDeviceModifyLeavesProfile(t *testing.T) {
+	f := newNMRunner()
+	b := nmBackend{run: f, device: true}
+	if err := b.Apply("wlp3s0", Settings{Servers: []string{"9.9.9.9"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.ran("nmcli"); len(got) != 1 || got[0] != "nmcli device modify wlp3s0 ipv4.dns 9.9.9.9 ipv4.ignore-auto-dns yes ipv6.dns  ipv6.ignore-auto-dns yes" {
+		t.Errorf("Unexpected commands: %q", got)
+	}
+	if err := b.Reset("wlp3s0", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.ran("nmcli device reapply"); len(got) != 1 {
+		t.Errorf("Reset must reapply the profile, ran %q", f.ran("nmcli"))
+	}
+	if b.Durability() != untilReboot || (nmBackend{}).Durability() != persistent {
+		t.Error("Unexpected NetworkManager durability")
+	}
+}
@@ -0,0 +1,80 @@
+//go:build linux
+
+package platform
+
+// resolvconf(8) backend for systems where openresolv or Debian's
+// resolvconf owns /etc/resolv.conf. Servers are registered as an
+// interface-scoped record so they survive DHCP renewals, which rewrite
+// only the DHCP client's own record.
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"dns-helper/internal/resolvconf"
+	"dns-helper/internal/util"
+)
+
+type resolvconfBackend struct {
+	run util.Runner
+}
+
+func (resolvconfBackend) Name() string { return "resolvconf" }
+
+// Durability: records live under /run, so they are gone after a reboot
+func (resolvconfBackend) Durability() Durability { return untilReboot }
+
+// Available requires the resolvconf binary and a resolv.conf it manages,
+// so the resolvconf shim shipped with systemd-resolved is not picked up
+func (resolvconfBackend) Available() bool {
+	if !fileExists("/sbin/resolvconf") && !fileExists("/usr/sbin/resolvconf") {
+		return false
+	}
+	info, err := resolvconf.Inspect(resolvConfPath)
+	return err == nil && info.ManagedBy == "resolvconf"
+}
+
+// record names the interface-scoped record owned by dns-helper
+func record(iface string) string {
+	return iface + ".dns-helper"
+}
+
+// openresolv reports whether resolvconf is openresolv rather than Debian's
+// implementation; only openresolv has -x and -f
+func (b resolvconfBackend) openresolv() bool {
+	out := b.run.Run(3*time.Second, "resolvconf", "--version")
+	return out.Err == nil && strings.HasPrefix(out.Stdout, "openresolv")
+}
+
+func (b resolvconfBackend) Apply(iface string, s Settings, dryRun bool) error {
+	f := &resolvconf.File{}
+	f.SetNameservers(s.Servers)
+	f.SetSearch(s.Search)
+	f.SetOptions(s.Options)
+	args := []string{"-a", record(iface)}
+	if b.openresolv() {
+		// exclusive: only this record is used while it exists
+		args = append(args, "-x")
+	} else {
+		fmt.Printf("Note: Debian resolvconf merges records by /etc/resolvconf/interface-order; servers from %s's DHCP record may still be listed\n", iface)
+	}
+	line := "resolvconf " + strings.Join(args, " ")
+	if dryRun {
+		fmt.Printf("[DRY-RUN] Would run: %s <<EOF\n%sEOF\n", line, f.Bytes())
+		return nil
+	}
+	out := b.run.RunInput(5*time.Second, string(f.Bytes()), "resolvconf", args...)
+	if out.Err != nil {
+		return out.Err
+	}
+	return nil
+}
+
+func (b resolvconfBackend) Reset(iface string, dryRun bool) error {
+	args := []string{"-d", record(iface)}
+	if b.openresolv() {
+		args = append(args, "-f") // a missing record is not an error
+	}
+	return runCmd(b.run, dryRun, 5*time.Second, "resolvconf", args...)
+}
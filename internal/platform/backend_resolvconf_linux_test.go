@@ -0,0 +1,66 @@
+//go:build linux
+
+package platform
+
+import (
+	"strings"
+	"testing"
+
+	"dns-helper/internal/util"
+)
+
+func TestResolvconfApplyOpenresolv(t *testing.T) {
+	f := &fakeRunner{outputs: map[string]util.CmdResult{
+		"resolvconf --version": {Stdout: "openresolv 3.12.0"},
+	}}
+	b := resolvconfBackend{run: f}
+	s := Settings{Servers: []string{"1.1.1.1", "1.0.0.1"}, Search: []string{"corp.example"}, Options: []string{"rotate"}}
+	if err := b.Apply("eth0", s, false); err != nil {
+		t.Fatal(err)
+	}
+	got := f.ran("resolvconf -a")
+	if len(got) != 1 || got[0] != "resolvconf -a eth0.dns-helper -x" {
+		t.Fatalf("Unexpected commands: %v", got)
+	}
+	want := "nameserver 1.1.1.1\nnameserver 1.0.0.1\nsearch corp.example\noptions rotate\n"
+	if in := f.inputs[got[0]]; in != want {
+		t.Errorf("Unexpected record:\n%s\nwant:\n%s", in, want)
+	}
+}
+
+func TestResolvconfApplyDebian(t *testing.T) {
+	f := &fakeRunner{outputs: map[string]util.CmdResult{}}
+	b := resolvconfBackend{run: f}
+	if err := b.Apply("eth0", Settings{Servers: []string{"9.9.9.9"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.ran("resolvconf -a"); len(got) != 1 || got[0] != "resolvconf -a eth0.dns-helper" {
+		t.Errorf("Unexpected commands: %v", got)
+	}
+}
+
+func TestResolvconfReset(t *testing.T) {
+	f := &fakeRunner{outputs: map[string]util.CmdResult{
+		"resolvconf --version": {Stdout: "openresolv 3.12.0"},
+	}}
+	b := resolvconfBackend{run: f}
+	if err := b.Reset("tun0", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.ran("resolvconf -d"); len(got) != 1 || got[0] != "resolvconf -d tun0.dns-helper -f" {
+		t.Errorf("Unexpected commands: %v", got)
+	}
+}
+
+func TestResolvconfDryRun(t *testing.T) {
+	f := &fakeRunner{outputs: map[string]util.CmdResult{}}
+	b := resolvconfBackend{run: f}
+	if err := b.Apply("eth0", Settings{Servers: []string{"9.9.9.9"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range f.calls {
+		if !strings.HasSuffix(c, "--version") {
+			t.Errorf("Dry run ran %q", c)
+		}
+	}
+}
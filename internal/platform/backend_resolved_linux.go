@@ -0,0 +1,228 @@
+//go:build linux
+
+package platform
+
+// Native systemd-resolved backend over D-Bus (org.freedesktop.resolve1),
+// so nothing depends on the text output of resolvectl.
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	resolvedDest    = "org.freedesktop.resolve1"
+	resolvedPath    = "/org/freedesktop/resolve1"
+	resolvedManager = "org.freedesktop.resolve1.Manager"
+)
+
+// busObject is the part of dbus.BusObject the backend uses; tests provide
+// an in-process fake.
+type busObject interface {
+	Call(method string, flags dbus.Flags, args ...interface{}) *dbus.Call
+	GetProperty(p string) (dbus.Variant, error)
+}
+
+// linkAddress is the (family, address) pair of SetLinkDNS
+type linkAddress struct {
+	Family  int32
+	Address []byte
+}
+
+// linkDomain is the (domain, routing-only) pair of SetLinkDomains
+type linkDomain struct {
+	Domain      string
+	RoutingOnly bool
+}
+
+type resolvedBackend struct {
+	bus     func() (busObject, error)
+	ifindex func(iface string) (int, error)
+	ifname  func(index int) (string, error)
+}
+
+var resolvedBus = resolvedBackend{
+	bus: func() (busObject, error) {
+		conn, err := dbus.SystemBus()
+		if err != nil {
+			return nil, err
+		}
+		return conn.Object(resolvedDest, dbus.ObjectPath(resolvedPath)), nil
+	},
+	ifindex: func(iface string) (int, error) {
+		i, err := net.InterfaceByName(iface)
+		if err != nil {
+			return 0, err
+		}
+		return i.Index, nil
+	},
+	ifname: func(index int) (string, error) {
+		i, err := net.InterfaceByIndex(index)
+		if err != nil {
+			return "", err
+		}
+		return i.Name, nil
+	},
+}
+
+func (resolvedBackend) Name() string { return "systemd-resolved" }
+
+func (resolvedBackend) Durability() Durability { return runtimeOnly }
+
+func (b resolvedBackend) Available() bool {
+	obj, err := b.bus()
+	if err != nil {
+		return false
+	}
+	_, err = obj.GetProperty(resolvedManager + ".DNS")
+	return err == nil
+}
+
+func (b resolvedBackend) call(dryRun bool, method string, args ...interface{}) error {
+	if dryRun {
+		fmt.Printf("[DRY-RUN] Would call %s.%s%v\n", resolvedManager, method, args)
+		return nil
+	}
+	obj, err := b.bus()
+	if err != nil {
+		return err
+	}
+	// without root, resolved asks polkit, which may prompt through an agent
+	var flags dbus.Flags
+	if os.Geteuid() != 0 {
+		flags = dbus.FlagAllowInteractiveAuthorization
+	}
+	if err := obj.Call(resolvedManager+"."+method, flags, args...).Err; err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	return nil
+}
+
+func (b resolvedBackend) Apply(iface string, s Settings, dryRun bool) error {
+	idx, err := b.ifindex(iface)
+	if err != nil {
+		return err
+	}
+	addrs := make([]linkAddress, 0, len(s.Servers))
+	for _, server := range s.Servers {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			return fmt.Errorf("invalid DNS server address: %s", server)
+		}
+		if v4 := ip.To4(); v4 != nil {
+			addrs = append(addrs, linkAddress{syscall.AF_INET, v4})
+		} else {
+			addrs = append(addrs, linkAddress{syscall.AF_INET6, ip.To16()})
+		}
+	}
+	if err := b.call(dryRun, "SetLinkDNS", int32(idx), addrs); err != nil {
+		return err
+	}
+	warnResolvedOptions(s.Options)
+	if len(s.Search) == 0 {
+		return nil
+	}
+	var current []string
+	if links, err := b.links(); err == nil {
+		current = linkDomains(links, iface)
+	}
+	return b.SetDomains(iface, withRouting(s.Search, current), dryRun)
+}
+
+// SetDomains sets the search and routing ("~" prefixed) domains of a link
+func (b resolvedBackend) SetDomains(iface string, domains []string, dryRun bool) error {
+	idx, err := b.ifindex(iface)
+	if err != nil {
+		return err
+	}
+	doms := make([]linkDomain, 0, len(domains))
+	for _, d := range domains {
+		if len(d) > 1 && d[0] == '~' {
+			doms = append(doms, linkDomain{d[1:], true})
+		} else {
+			doms = append(doms, linkDomain{d, false})
+		}
+	}
+	return b.call(dryRun, "SetLinkDomains", int32(idx), doms)
+}
+
+func (b resolvedBackend) Reset(iface string, dryRun bool) error {
+	idx, err := b.ifindex(iface)
+	if err != nil {
+		return err
+	}
+	return b.call(dryRun, "RevertLink", int32(idx))
+}
+
+// links reads the per-link DNS servers and domains from the manager
+func (b resolvedBackend) links() ([]Link, error) {
+	obj, err := b.bus()
+	if err != nil {
+		return nil, err
+	}
+	v, err := obj.GetProperty(resolvedManager + ".DNS")
+	if err != nil {
+		return nil, err
+	}
+	var servers []struct {
+		Ifindex int32
+		Family  int32
+		Address []byte
+	}
+	if err := v.Store(&servers); err != nil {
+		return nil, fmt.Errorf("decoding DNS property: %v", err)
+	}
+	v, err = obj.GetProperty(resolvedManager + ".Domains")
+	if err != nil {
+		return nil, err
+	}
+	var domains []struct {
+		Ifindex     int32
+		Domain      string
+		RoutingOnly bool
+	}
+	if err := v.Store(&domains); err != nil {
+		return nil, fmt.Errorf("decoding Domains property: %v", err)
+	}
+
+	byIndex := map[int32]*Link{}
+	link := func(idx int32) *Link {
+		if l, ok := byIndex[idx]; ok {
+			return l
+		}
+		name, err := b.ifname(int(idx))
+		if err != nil {
+			name = fmt.Sprintf("if%d", idx)
+		}
+		byIndex[idx] = &Link{Iface: name}
+		return byIndex[idx]
+	}
+	for _, s := range servers {
+		if s.Ifindex == 0 {
+			continue // global servers from resolved.conf
+		}
+		l := link(s.Ifindex)
+		l.Servers = append(l.Servers, net.IP(s.Address).String())
+	}
+	for _, d := range domains {
+		if d.Ifindex == 0 {
+			continue
+		}
+		l := link(d.Ifindex)
+		if d.RoutingOnly {
+			l.Domains = append(l.Domains, "~"+d.Domain)
+		} else {
+			l.Domains = append(l.Domains, d.Domain)
+		}
+	}
+	out := make([]Link, 0, len(byIndex))
+	for _, l := range byIndex {
+		out = append(out, *l)
+	}
+	sortLinks(out)
+	return out, nil
+}
@@ -0,0 +1,187 @@
+//go:build linux
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeBus is an in-process stand-in for the resolve1 manager object
+type fakeBus struct {
+	calls []string
+	props map[string]interface{}
+	err   error
+}
+
+func (f *fakeBus) Call(method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+	f.calls = append(f.calls, fmt.Sprintf("%s%v", method, args))
+	return &dbus.Call{Err: f.err}
+}
+
+func (f *fakeBus) GetProperty(p string) (dbus.Variant, error) {
+	v, ok := f.props[p]
+	if !ok {
+		return dbus.Variant{}, errors.New("no such property: " + p)
+	}
+	return dbus.MakeVariant(v), nil
+}
+
+func newFakeResolved(bus *fakeBus) resolvedBackend {
+	names := map[int]string{2: "eth0", 5: "tun0"}
+	return resolvedBackend{
+		bus: func() (busObject, error) { return bus, nil },
+		ifindex: func(iface string) (int, error) {
+			for i, n := range names {
+				if n == iface {
+					return i, nil
+				}
+			}
+			return 0, errors.New("no such interface: " + iface)
+		},
+		ifname: func(index int) (string, error) { return names[index], nil },
+	}
+}
+
+func TestResolvedSetLinkDNS(t *testing.T) {
+	bus := &fakeBus{}
+	b := newFakeResolved(bus)
+	if err := b.Apply("eth0", Settings{Servers: []string{"1.1.1.1", "2606:4700:4700::1111"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(bus.calls) != 1 {
+		t.Fatalf("Expected one call, got %v", bus.calls)
+	}
+	expected := fmt.Sprintf("%s.SetLinkDNS%v", resolvedManager, []interface{}{int32(2), []linkAddress{
+		{syscall.AF_INET, []byte{1, 1, 1, 1}},
+		{syscall.AF_INET6, []byte{0x26, 0x06, 0x47, 0, 0x47, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x11, 0x11}},
+	}})
+	if bus.calls[0] != expected {
+		t.Errorf("Unexpected call:\n%s\nexpected:\n%s", bus.calls[0], expected)
+	}
+}
+
+// switch custom with IPv6 servers: the addresses survive port stripping
+// and reach SetLinkDNS whole
+func TestResolvedSwitchIPv6(t *testing.T) {
+	bus := &fakeBus{}
+	b := newFakeResolved(bus)
+	servers := stripPorts([]string{"2606:4700::1111", "[2606:4700::1001]:53"})
+	if _, err := switchIface([]backend{b}, "eth0", Settings{Servers: servers}, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("%s.SetLinkDNS%v", resolvedManager, []interface{}{int32(2), []linkAddress{
+		{syscall.AF_INET6, net.ParseIP("2606:4700::1111").To16()},
+		{syscall.AF_INET6, net.ParseIP("2606:4700::1001").To16()},
+	}})
+	if len(bus.calls) != 1 || bus.calls[0] != expected {
+		t.Errorf("Unexpected calls:\n%v\nexpected:\n%s", bus.calls, expected)
+	}
+}
+
+func TestResolvedSetLinkDomains(t *testing.T) {
+	bus := &fakeBus{}
+	b := newFakeResolved(bus)
+	if err := b.SetDomains("tun0", []string{"corp.example", "~internal.example"}, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("%s.SetLinkDomains%v", resolvedManager, []interface{}{int32(5), []linkDomain{
+		{"corp.example", false}, {"internal.example", true},
+	}})
+	if len(bus.calls) != 1 || bus.calls[0] != expected {
+		t.Errorf("Unexpected calls: %v", bus.calls)
+	}
+}
+
+func TestResolvedRevertLink(t *testing.T) {
+	bus := &fakeBus{}
+	b := newFakeResolved(bus)
+	if err := b.Reset("tun0", false); err != nil {
+		t.Fatal(err)
+	}
+	if len(bus.calls) != 1 || bus.calls[0] != resolvedManager+".RevertLink[5]" {
+		t.Errorf("Unexpected calls: %v", bus.calls)
+	}
+}
+
+func TestResolvedDryRunDoesNotCall(t *testing.T) {
+	bus := &fakeBus{}
+	b := newFakeResolved(bus)
+	if err := b.Apply("eth0", Settings{Servers: []string{"1.1.1.1"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(bus.calls) != 0 {
+		t.Errorf("Dry run made calls: %v", bus.calls)
+	}
+}
+
+func TestResolvedCallError(t *testing.T) {
+	bus := &fakeBus{err: errors.New("Access denied")}
+	b := newFakeResolved(bus)
+	if err := b.Apply("eth0", Settings{Servers: []string{"1.1.1.1"}}, false); err == nil {
+		t.Error("Expected the bus error to be returned")
+	}
+	if err := b.Apply("eth0", Settings{Servers: []string{"not-an-ip"}}, false); err == nil {
+		t.Error("Expected error for an invalid address")
+	}
+}
+
+func TestResolvedLinks(t *testing.T) {
+	// shapes as decoded from the wire: a(iiay) and a(isb)
+	bus := &fakeBus{props: map[string]interface{}{
+		resolvedManager + ".DNS": [][]interface{}{
+			{int32(0), int32(syscall.AF_INET), []byte{9, 9, 9, 9}},
+			{int32(2), int32(syscall.AF_INET), []byte{192, 168, 1, 1}},
+			{int32(2), int32(syscall.AF_INET6), []byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}},
+			{int32(5), int32(syscall.AF_INET), []byte{10, 8, 0, 1}},
+		},
+		resolvedManager + ".Domains": [][]interface{}{
+			{int32(2), "lan", false},
+			{int32(5), "corp.example", true},
+		},
+	}}
+	b := newFakeResolved(bus)
+
+	links, err := b.links()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("Expected 2 links (global servers skipped), got %+v", links)
+	}
+	eth0, tun0 := links[0], links[1]
+	if eth0.Iface != "eth0" || len(eth0.Servers) != 2 || eth0.Servers[1] != "fe80::1" || eth0.Domains[0] != "lan" {
+		t.Errorf("Unexpected eth0: %+v", eth0)
+	}
+	if tun0.Iface != "tun0" || tun0.Servers[0] != "10.8.0.1" || tun0.Domains[0] != "~corp.example" {
+		t.Errorf("Unexpected tun0: %+v", tun0)
+	}
+}
+
+func TestResolvedApplySearchKeepsRoutingDomains(t *testing.T) {
+	bus := &fakeBus{props: map[string]interface{}{
+		resolvedManager + ".DNS": [][]interface{}{
+			{int32(5), int32(syscall.AF_INET), []byte{10, 8, 0, 1}},
+		},
+		resolvedManager + ".Domains": [][]interface{}{
+			{int32(5), "old.example", false},
+			{int32(5), "corp.example", true},
+		},
+	}}
+	b := newFakeResolved(bus)
+	s := Settings{Servers: []string{"10.8.0.53"}, Search: []string{"svc.cluster.local"}, Options: []string{"ndots:5"}}
+	if err := b.Apply("tun0", s, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("%s.SetLinkDomains%v", resolvedManager, []interface{}{int32(5), []linkDomain{
+		{"svc.cluster.local", false}, {"corp.example", true},
+	}})
+	if len(bus.calls) != 2 || bus.calls[1] != expected {
+		t.Errorf("Unexpected calls: %v", bus.calls)
+	}
+}
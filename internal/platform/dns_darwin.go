@@ -3,7 +3,7 @@
 package platform
 
 // macOS implementation of DNS operations
-// Uses networksetup, dscacheutil, and killall commands
+// Uses networksetup; caches are flushed by FlushCaches
 
 import (
 	"errors"
@@ -34,30 +34,54 @@ func listServices() ([]string, error) {
 	return svcs, nil
 }
 
-func stripPorts(servers []string) []string {
-	out := make([]string, 0, len(servers))
-	for _, s := range servers {
-		if i := strings.Index(s, ":"); i > 0 {
-			out = append(out, s[:i])
-		} else {
-			out = append(out, s)
+// targetServices selects network services by name; by default every
+// service is configured, which is also what --all-interfaces means here.
+func targetServices(opts Options) ([]string, error) {
+	svcs, err := listServices()
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Interfaces) == 0 || opts.AllInterfaces {
+		return svcs, nil
+	}
+	known := map[string]bool{}
+	for _, s := range svcs {
+		known[s] = true
+	}
+	for _, i := range opts.Interfaces {
+		if !known[i] {
+			return nil, fmt.Errorf("unknown network service: %s (available: %s)", i, strings.Join(svcs, ", "))
 		}
 	}
-	return out
+	return opts.Interfaces, nil
 }
 
-func SwitchAll(servers []string, dryRun bool) error {
-	svcs, err := listServices()
+func SwitchAll(settings Settings, opts Options) error {
+	dryRun := opts.DryRun
+	if len(settings.Options) > 0 {
+		return fmt.Errorf("resolver options: %w", ErrNotSupported)
+	}
+	// network service settings are saved, so every change is persistent
+	if opts.Mode == ModeRuntime {
+		return fmt.Errorf("--runtime: %w", ErrNotSupported)
+	}
+	if opts.Backend != "" {
+		fmt.Printf("Note: only networksetup is used on this platform; ignoring backend %s\n", opts.Backend)
+	}
+	svcs, err := targetServices(opts)
 	if err != nil {
 		return err
 	}
 
 	// Remove port numbers from DNS servers
+	servers := settings.Servers
 	cleanServers := stripPorts(servers)
 
 	fmt.Printf("Found %d network services: %v\n", len(svcs), svcs)
 	fmt.Printf("Setting DNS servers: %v (cleaned: %v)\n", servers, cleanServers)
+	printExtras(settings)
 
+	saved := loadSavedSearch()
 	for _, s := range svcs {
 		if dryRun {
 			fmt.Printf("[DRY-RUN] Would set DNS for: %s\n", s)
@@ -68,25 +92,54 @@ func SwitchAll(servers []string, dryRun bool) error {
 		args := append([]string{"-setdnsservers", s}, cleanServers...)
 		out := util.Run(8*time.Second, "networksetup", args...)
 
+		if out.Err == nil && len(settings.Search) > 0 {
+			// keep the domains from before the first switch for reset
+			if _, ok := saved[s]; !ok {
+				saved[s] = searchDomains(s)
+				if err := saveSavedSearch(saved); err != nil {
+					return fmt.Errorf("recording the search domains of %s: %w", s, err)
+				}
+			}
+			args = append([]string{"-setsearchdomains", s}, settings.Search...)
+			out = util.Run(8*time.Second, "networksetup", args...)
+		}
+
 		if out.Err != nil {
 			fmt.Printf("Error setting DNS for %s: %v\n", s, out.Err)
-			if out.Stderr != "" {
-				fmt.Printf("Stderr: %s\n", out.Stderr)
-			}
 		} else {
 			fmt.Printf("Successfully set DNS for: %s\n", s)
 		}
 	}
 
-	if !dryRun {
-		fmt.Println("Flushing DNS cache...")
-		_ = util.Run(5*time.Second, "dscacheutil", "-flushcache").Err
+	return nil
+}
 
-		fmt.Println("Restarting mDNSResponder...")
-		_ = util.Run(5*time.Second, "killall", "-HUP", "mDNSResponder").Err
+// ActiveBackend names the service that manages DNS on this host
+func ActiveBackend() string {
+	return "networksetup"
+}
+
+// Details reports servers and search domains per network service
+func Details() ([]Link, error) {
+	st, err := Status()
+	if err != nil {
+		return nil, err
+	}
+	var out []Link
+	for svc, servers := range st {
+		out = append(out, Link{Iface: svc, Servers: servers, Domains: searchDomains(svc)})
 	}
+	sortLinks(out)
+	return out, nil
+}
 
-	return nil
+// searchDomains lists the search domains set on a network service
+func searchDomains(svc string) []string {
+	res := util.Run(5*time.Second, "networksetup", "-getsearchdomains", svc)
+	if res.Err != nil || strings.Contains(res.Stdout, "There aren't any") {
+		return nil
+	}
+	return strings.Fields(res.Stdout)
 }
 
 func Status() (map[string][]string, error) {
@@ -110,40 +163,56 @@ func Status() (map[string][]string, error) {
 	return res, nil
 }
 
-func ResetToDHCP(dryRun bool) error {
-	svcs, err := listServices()
+func ResetToDHCP(opts Options) error {
+	dryRun := opts.DryRun
+	if opts.Mode == ModeRuntime {
+		return fmt.Errorf("--runtime: %w", ErrNotSupported)
+	}
+	if opts.Backend != "" {
+		fmt.Printf("Note: only networksetup is used on this platform; ignoring backend %s\n", opts.Backend)
+	}
+	svcs, err := targetServices(opts)
 	if err != nil {
 		return err
 	}
 
 	fmt.Printf("Found %d network services to reset\n", len(svcs))
 
+	saved := loadSavedSearch()
 	for _, s := range svcs {
+		prev, changed := saved[s]
 		if dryRun {
 			fmt.Printf("[DRY-RUN] Would reset DNS for: %s\n", s)
+			if changed {
+				fmt.Printf("[DRY-RUN] Would restore search domains for %s: %v\n", s, prev)
+			}
 			continue
 		}
 
 		fmt.Printf("Resetting DNS for: %s\n", s)
 		// macOS: use "empty" to reset to DHCP defaults (not empty string)
 		out := util.Run(8*time.Second, "networksetup", "-setdnsservers", s, "empty")
+		// only search domains switch replaced are put back
+		if out.Err == nil && changed {
+			if len(prev) == 0 {
+				prev = []string{"empty"}
+			}
+			out = util.Run(8*time.Second, "networksetup", append([]string{"-setsearchdomains", s}, prev...)...)
+			if out.Err == nil {
+				delete(saved, s)
+			}
+		}
 
 		if out.Err != nil {
 			fmt.Printf("Error resetting DNS for %s: %v\n", s, out.Err)
-			if out.Stderr != "" {
-				fmt.Printf("Stderr: %s\n", out.Stderr)
-			}
 		} else {
 			fmt.Printf("Successfully reset DNS for: %s\n", s)
 		}
 	}
-
 	if !dryRun {
-		fmt.Println("Flushing DNS cache...")
-		_ = util.Run(5*time.Second, "dscacheutil", "-flushcache").Err
-
-		fmt.Println("Restarting mDNSResponder...")
-		_ = util.Run(5*time.Second, "killall", "-HUP", "mDNSResponder").Err
+		if err := saveSavedSearch(saved); err != nil {
+			return fmt.Errorf("recording the search domains: %w", err)
+		}
 	}
 
 	return nil
@@ -6,120 +6,208 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
-	"time"
 
-	"dns-helper/internal/util"
+	"dns-helper/internal/resolvconf"
 )
 
-func defaultIface() string {
-	out := util.Run(3*time.Second, "sh", "-c", "ip route show default | awk '{print $5}' | head -1")
-	if out.Err != nil || out.Stdout == "" {
-		return ""
+// resolvConfPath is the file edited by the fallback, replaced in tests
+var resolvConfPath = resolvconf.DefaultPath
+
+func SwitchAll(s Settings, opts Options) error {
+	ifaces, err := targetIfaces(opts)
+	if err != nil {
+		return err
 	}
-	return strings.TrimSpace(out.Stdout)
-}
+	servers := s.Servers
+	s.Servers = stripPorts(servers)
+
+	fmt.Printf("Setting DNS servers: %v (cleaned: %v)\n", servers, s.Servers)
+	printExtras(s)
 
-func stripPorts(servers []string) []string {
-	out := make([]string, 0, len(servers))
-	for _, s := range servers {
-		if i := strings.Index(s, ":"); i > 0 {
-			out = append(out, s[:i])
-		} else {
-			out = append(out, s)
+	if l, ok := activeLocalResolver(); ok {
+		// the upstreams of a local cache are the same for every interface
+		if !opts.Mode.allows(l.Durability()) {
+			return fmt.Errorf("%s: %s keeps its upstreams in its configuration, which survives reboots", opts.Mode.flag(), l.name)
 		}
+		noteIgnoredIfaces(l, opts)
+		fmt.Printf("%s points at a local %s; replacing its upstream servers\n", resolvConfPath, l.name)
+		if err := l.Apply(s, opts.DryRun); err != nil {
+			return fmt.Errorf("%s: %w", l.name, err)
+		}
+		fmt.Printf("%-15s ok via %s (%s)\n", "upstreams", l.name, l.Durability())
+		if !opts.DryRun {
+			remember(l.name, &Applied{Backend: l.name, Durability: l.Durability(), Servers: s.Servers})
+		}
+		return nil
 	}
-	return out
-}
 
-func SwitchAll(servers []string, dryRun bool) error {
-	iface := defaultIface()
-	cleanServers := stripPorts(servers)
-
-	fmt.Printf("Default interface: %s\n", iface)
-	fmt.Printf("Setting DNS servers: %v (cleaned: %v)\n", servers, cleanServers)
-
-	if iface != "" {
-		// systemd-resolved
-		if _, err := os.Stat("/run/systemd/resolve/stub-resolv.conf"); err == nil || fileExists("/usr/bin/resolvectl") || fileExists("/bin/resolvectl") {
-			args := append([]string{"dns", iface}, cleanServers...)
-			if !dryRun {
-				fmt.Printf("Using systemd-resolved for interface: %s\n", iface)
-				out := util.Run(5*time.Second, "resolvectl", args...)
-				if out.Err == nil {
-					fmt.Printf("Successfully set DNS via systemd-resolved\n")
-					return nil
-				} else {
-					fmt.Printf("systemd-resolved failed: %v\n", out.Err)
-				}
-			} else {
-				fmt.Printf("[DRY-RUN] Would use systemd-resolved for interface: %s\n", iface)
-			}
-		}
-		// NetworkManager
-		if fileExists("/usr/bin/nmcli") || fileExists("/bin/nmcli") {
-			args := append([]string{"con", "mod", iface, "ipv4.method", "manual", "ipv4.dns"}, strings.Join(cleanServers, ","))
-			if !dryRun {
-				fmt.Printf("Using NetworkManager for interface: %s\n", iface)
-				_ = util.Run(8*time.Second, "nmcli", args...).Err
-				_ = util.Run(5*time.Second, "nmcli", "con", "up", iface).Err
-				fmt.Printf("Successfully set DNS via NetworkManager\n")
-				return nil
-			} else {
-				fmt.Printf("[DRY-RUN] Would use NetworkManager for interface: %s\n", iface)
-			}
-		}
-	}
-	// Fallback: /etc/resolv.conf overwrite (risk: managed on some systems)
-	if !dryRun {
-		fmt.Println("Using fallback: direct /etc/resolv.conf modification")
-		content := "nameserver " + strings.Join(cleanServers, "\nnameserver ") + "\n"
-		err := os.WriteFile("/etc/resolv.conf", []byte(content), 0644)
+	fmt.Printf("Target interfaces: %v\n", ifaces)
+	cands, err := candidates(opts.Mode, opts.Backend)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	applied := 0
+	if len(cands) == 0 {
+		ifaces = nil // only the global fallback is available
+	}
+	for _, iface := range ifaces {
+		b, err := switchIface(cands, iface, s, opts.DryRun)
 		if err != nil {
-			return fmt.Errorf("failed to write /etc/resolv.conf: %v", err)
+			fmt.Printf("%-15s failed: %v\n", iface, err)
+			errs = append(errs, fmt.Errorf("%s: %w", iface, err))
+			continue
+		}
+		fmt.Printf("%-15s ok via %s (%s)\n", iface, b.Name(), b.Durability())
+		if !opts.DryRun {
+			remember(iface, &Applied{Backend: b.Name(), Durability: b.Durability(), Servers: s.Servers})
+		}
+		applied++
+	}
+	if applied > 0 || opts.Mode != ModeAuto {
+		return errors.Join(errs...)
+	}
+	// Fallback: edit the nameservers of /etc/resolv.conf, keeping everything else
+	fmt.Println("Using fallback: direct " + resolvConfPath + " modification")
+	edit := func(f *resolvconf.File) {
+		f.SetNameservers(s.Servers)
+		if len(s.Search) > 0 {
+			f.SetSearch(s.Search)
+		}
+		if len(s.Options) > 0 {
+			f.SetOptions(s.Options)
 		}
-		fmt.Printf("Successfully wrote DNS to /etc/resolv.conf\n")
-	} else {
-		fmt.Println("[DRY-RUN] Would modify /etc/resolv.conf")
+	}
+	if err := resolvconf.Update(resolvConfPath, edit, opts.Force, opts.DryRun); err != nil {
+		return fmt.Errorf("failed to update %s: %w", resolvConfPath, err)
+	}
+	if !opts.DryRun {
+		fmt.Printf("Successfully wrote DNS to %s\n", resolvConfPath)
+		remember("resolv.conf", &Applied{Backend: "resolv.conf", Durability: resolvConfDurability, Servers: s.Servers})
 	}
 	return nil
 }
 
+// resolvConfDurability: the file stays across reboots, but DHCP clients
+// rewrite it on renewal
+var resolvConfDurability = Durability{Reboot: true}
+
+// switchIface applies settings to one interface and returns the backend used
+func switchIface(cands []backend, iface string, s Settings, dryRun bool) (backend, error) {
+	var lastErr error
+	for _, b := range cands {
+		if dryRun {
+			fmt.Printf("[DRY-RUN] Would use %s for interface: %s\n", b.Name(), iface)
+		}
+		err := b.Apply(iface, s, dryRun)
+		if err == nil {
+			return b, nil
+		}
+		fmt.Printf("%s failed for %s: %v\n", b.Name(), iface, err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no per-interface DNS backend available")
+	}
+	return nil, lastErr
+}
+
+// candidates are the backends to try in mode, with the one named prefer
+// first when it is available and fits the mode
+func candidates(mode Mode, prefer string) ([]backend, error) {
+	out, note, err := orderedCandidates(mode, prefer)
+	if note != "" {
+		fmt.Println("Note: " + note)
+	}
+	return out, err
+}
+
+// orderedCandidates is candidates without printing; note says why prefer
+// was ignored
+func orderedCandidates(mode Mode, prefer string) ([]backend, string, error) {
+	out, err := modeCandidates(mode)
+	if err != nil || prefer == "" {
+		return out, "", err
+	}
+	for _, b := range modeBackends {
+		if !strings.EqualFold(b.Name(), prefer) {
+			continue
+		}
+		switch {
+		case !mode.allows(b.Durability()):
+			return out, fmt.Sprintf("preferred backend %s does not fit %s; ignoring it", b.Name(), mode.flag()), nil
+		case !b.Available():
+			return out, fmt.Sprintf("preferred backend %s is not in use here; ignoring it", b.Name()), nil
+		}
+		rest := slices.DeleteFunc(out, func(o backend) bool { return o.Name() == b.Name() })
+		return append([]backend{b}, rest...), "", nil
+	}
+	var names []string
+	for _, b := range modeBackends {
+		names = append(names, b.Name())
+	}
+	return nil, "", fmt.Errorf("unknown backend %q (known: %s)", prefer, strings.Join(names, ", "))
+}
+
+// modeCandidates are the backends to try in mode: with --persist or
+// --runtime the available ones whose durability fits, otherwise every
+// available backend in order of preference
+func modeCandidates(mode Mode) ([]backend, error) {
+	if mode == ModeAuto {
+		return availableBackends(), nil
+	}
+	var out, fit []backend
+	for _, b := range modeBackends {
+		if !mode.allows(b.Durability()) {
+			continue
+		}
+		fit = append(fit, b)
+		if b.Available() {
+			out = append(out, b)
+		}
+	}
+	if len(out) == 0 {
+		var names []string
+		for _, b := range fit {
+			names = appendUnique(names, b.Name())
+		}
+		return nil, fmt.Errorf("%s needs one of %s, and none is in use", mode.flag(), strings.Join(names, ", "))
+	}
+	return out, nil
+}
+
+// notePersisted points at reset --persist when a runtime reset leaves
+// persistent configuration behind
+func notePersisted(iface string) {
+	for _, b := range modeBackends {
+		if p, ok := b.(persister); ok && b.Available() && p.persisted(iface) {
+			fmt.Printf("Note: %s still has persistent %s settings; use 'dns-helper reset --persist' to remove them\n", iface, b.Name())
+		}
+	}
+}
+
+func availableBackends() []backend {
+	var out []backend
+	for _, b := range backends {
+		if b.Available() {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// Status reports DNS servers per interface, plus the contents of resolv.conf
 func Status() (map[string][]string, error) {
-	res := map[string][]string{"system": {}}
-	// resolvectl
-	if fileExists("/usr/bin/resolvectl") || fileExists("/bin/resolvectl") {
-		out := util.Run(5*time.Second, "resolvectl", "status")
-		if out.Err == nil && out.Stdout != "" {
-			lines := strings.Split(out.Stdout, "\n")
-			var current []string
-			for _, l := range lines {
-				if strings.Contains(l, "Current DNS Server:") {
-					current = append(current, strings.TrimSpace(strings.Split(l, ":")[1]))
-				}
-				if strings.Contains(l, "DNS Servers:") {
-					s := strings.TrimSpace(strings.Split(l, ":")[1])
-					if s != "" {
-						current = append(current, strings.Fields(s)...)
-					}
-				}
-			}
-			if len(current) > 0 {
-				res["systemd-resolved"] = current
-			}
-		}
-	}
-	// /etc/resolv.conf
-	data, err := os.ReadFile("/etc/resolv.conf")
-	if err == nil {
-		lines := strings.Split(string(data), "\n")
-		for _, l := range lines {
-			if strings.HasPrefix(strings.TrimSpace(l), "nameserver") {
-				fields := strings.Fields(l)
-				if len(fields) >= 2 {
-					res["resolv.conf"] = append(res["resolv.conf"], fields[1])
-				}
-			}
+	links, err := Details()
+	if err != nil {
+		return nil, err
+	}
+	res := map[string][]string{}
+	for _, l := range links {
+		if len(l.Servers) > 0 {
+			res[l.Iface] = l.Servers
 		}
 	}
 	if len(res) == 0 {
@@ -128,59 +216,151 @@ func Status() (map[string][]string, error) {
 	return res, nil
 }
 
+// Details reports servers, domains and options per interface, plus a
+// "resolv.conf" entry for the file itself, with the changes dns-helper made
+func Details() ([]Link, error) {
+	var out []Link
+	// systemd-resolved: per-link servers and domains
+	if fileExists("/usr/bin/resolvectl") || fileExists("/bin/resolvectl") {
+		if links, err := Links(); err == nil {
+			out = append(out, links...)
+		}
+	}
+	if f, err := resolvconf.Read(resolvConfPath); err == nil {
+		out = append(out, Link{Iface: "resolv.conf", Servers: f.Nameservers(), Domains: f.Search(), Options: f.Options()})
+	}
+	if l, ok := activeLocalResolver(); ok {
+		out = append(out, Link{Iface: l.name, Servers: l.upstreams()})
+	}
+	if len(out) == 0 {
+		return nil, errors.New("could not read DNS status")
+	}
+	state := loadApplied()
+	for i := range out {
+		if a, ok := state[out[i].Iface]; ok {
+			out[i].Applied = &a
+		}
+	}
+	return out, nil
+}
+
+// ActiveBackend names the service that manages DNS on this host
+func ActiveBackend() string {
+	if l, ok := activeLocalResolver(); ok {
+		return l.name
+	}
+	if b := availableBackends(); len(b) > 0 {
+		return b[0].Name()
+	}
+	return "resolv.conf"
+}
+
+func resolvedActive() bool {
+	return fileExists("/run/systemd/resolve/stub-resolv.conf") || fileExists("/usr/bin/resolvectl") || fileExists("/bin/resolvectl")
+}
+
+func nmAvailable() bool {
+	return fileExists("/usr/bin/nmcli") || fileExists("/bin/nmcli")
+}
+
 func fileExists(p string) bool {
 	_, err := os.Stat(p)
 	return err == nil
 }
 
-func ResetToDHCP(dryRun bool) error {
-	iface := defaultIface()
-
-	fmt.Printf("Default interface: %s\n", iface)
-
-	if iface != "" {
-		// systemd-resolved
-		if _, err := os.Stat("/run/systemd/resolve/stub-resolv.conf"); err == nil || fileExists("/usr/bin/resolvectl") || fileExists("/bin/resolvectl") {
-			if !dryRun {
-				fmt.Printf("Using systemd-resolved to reset DNS for interface: %s\n", iface)
-				// Linux: use "dhcp" to reset to DHCP defaults
-				out := util.Run(5*time.Second, "resolvectl", "dns", iface, "dhcp")
-				if out.Err == nil {
-					fmt.Printf("Successfully reset DNS via systemd-resolved\n")
-					return nil
-				} else {
-					fmt.Printf("systemd-resolved reset failed: %v\n", out.Err)
-				}
-			} else {
-				fmt.Printf("[DRY-RUN] Would use systemd-resolved to reset DNS for interface: %s\n", iface)
-			}
-		}
-		// NetworkManager
-		if fileExists("/usr/bin/nmcli") || fileExists("/bin/nmcli") {
-			if !dryRun {
-				fmt.Printf("Using NetworkManager to reset DNS for interface: %s\n", iface)
-				// Linux: use "auto" to reset to DHCP defaults
-				_ = util.Run(8*time.Second, "nmcli", "con", "mod", iface, "ipv4.dns", "auto").Err
-				_ = util.Run(5*time.Second, "nmcli", "con", "up", iface).Err
-				fmt.Printf("Successfully reset DNS via NetworkManager\n")
-				return nil
-			} else {
-				fmt.Printf("[DRY-RUN] Would use NetworkManager to reset DNS for interface: %s\n", iface)
-			}
-		}
-	}
-	// Fallback: restore /etc/resolv.conf
-	if !dryRun {
-		fmt.Println("Using fallback: restore /etc/resolv.conf")
-		// Try to restore from backup or use minimal content
-		content := "# DNS settings reset to DHCP defaults\n# Generated by dns-helper\n"
-		err := os.WriteFile("/etc/resolv.conf", []byte(content), 0644)
+// noteIgnoredIfaces says that interface flags do not apply to a local
+// resolver, whose upstreams serve every interface
+func noteIgnoredIfaces(l localResolver, opts Options) {
+	var flags []string
+	for _, iface := range opts.Interfaces {
+		flags = append(flags, "--interface "+iface)
+	}
+	if opts.AllInterfaces {
+		flags = append(flags, "--all-interfaces")
+	}
+	if len(flags) > 0 {
+		fmt.Printf("Note: %s forwards the queries of every interface; ignoring %s\n", l.name, strings.Join(flags, " "))
+	}
+}
+
+func ResetToDHCP(opts Options) error {
+	ifaces, err := targetIfaces(opts)
+	if err != nil {
+		return err
+	}
+
+	if l, ok := activeLocalResolver(); ok {
+		if !opts.Mode.allows(l.Durability()) {
+			return fmt.Errorf("%s: %s keeps its upstreams in its configuration, which survives reboots", opts.Mode.flag(), l.name)
+		}
+		noteIgnoredIfaces(l, opts)
+		fmt.Printf("%s points at a local %s; restoring its upstream configuration\n", resolvConfPath, l.name)
+		if err := l.Reset(opts.DryRun); err != nil {
+			return fmt.Errorf("%s: %w", l.name, err)
+		}
+		if !opts.DryRun {
+			remember(l.name, nil)
+		}
+		return nil
+	}
+
+	fmt.Printf("Target interfaces: %v\n", ifaces)
+	cands, err := candidates(opts.Mode, opts.Backend)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	applied := 0
+	if len(cands) == 0 {
+		ifaces = nil // only the global fallback is available
+	}
+	for _, iface := range ifaces {
+		backend, err := resetIface(cands, iface, opts.DryRun)
 		if err != nil {
-			return fmt.Errorf("failed to reset /etc/resolv.conf: %v", err)
+			fmt.Printf("%-15s failed: %v\n", iface, err)
+			errs = append(errs, fmt.Errorf("%s: %w", iface, err))
+			continue
+		}
+		fmt.Printf("%-15s reset via %s\n", iface, backend)
+		if !opts.DryRun {
+			remember(iface, nil)
+		}
+		applied++
+		if opts.Mode != ModePersist {
+			notePersisted(iface)
 		}
-		fmt.Printf("Successfully reset /etc/resolv.conf\n")
-	} else {
-		fmt.Println("[DRY-RUN] Would restore /etc/resolv.conf")
+	}
+	if applied > 0 || opts.Mode != ModeAuto {
+		return errors.Join(errs...)
+	}
+	// Fallback: restore the resolv.conf saved by switch
+	fmt.Println("Using fallback: restore " + resolvConfPath)
+	if err := resolvconf.Restore(resolvConfPath, opts.DryRun); err != nil {
+		return fmt.Errorf("failed to reset %s: %w", resolvConfPath, err)
+	}
+	if !opts.DryRun {
+		fmt.Printf("Successfully reset %s\n", resolvConfPath)
+		remember("resolv.conf", nil)
 	}
 	return nil
 }
+
+// resetIface restores DHCP-provided DNS on one interface
+func resetIface(cands []backend, iface string, dryRun bool) (string, error) {
+	var lastErr error
+	for _, b := range cands {
+		if dryRun {
+			fmt.Printf("[DRY-RUN] Would use %s to reset DNS for interface: %s\n", b.Name(), iface)
+		}
+		err := b.Reset(iface, dryRun)
+		if err == nil {
+			return b.Name(), nil
+		}
+		fmt.Printf("%s reset failed for %s: %v\n", b.Name(), iface, err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no per-interface DNS backend available")
+	}
+	return "", lastErr
+}
@@ -4,6 +4,7 @@ package platform
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -11,45 +12,106 @@ import (
 	"dns-helper/internal/util"
 )
 
-func stripPorts(servers []string) []string {
-	out := make([]string, 0, len(servers))
-	for _, s := range servers {
-		if i := strings.Index(s, ":"); i > 0 {
-			out = append(out, s[:i])
-		} else {
-			out = append(out, s)
-		}
+// adapterSelector is the PowerShell pipeline head selecting target adapters:
+// every adapter that is up by default, or the named ones.
+func adapterSelector(opts Options) string {
+	if len(opts.Interfaces) == 0 || opts.AllInterfaces {
+		return `Get-NetAdapter | Where-Object {$_.Status -eq 'Up'}`
 	}
-	return out
+	return "Get-NetAdapter -Name " + psList(opts.Interfaces)
 }
 
-func SwitchAll(servers []string, dryRun bool) error {
+func SwitchAll(settings Settings, opts Options) error {
+	dryRun := opts.DryRun
+	if len(settings.Options) > 0 {
+		return fmt.Errorf("resolver options: %w", ErrNotSupported)
+	}
+	// adapter settings are saved, so every change is persistent
+	if opts.Mode == ModeRuntime {
+		return fmt.Errorf("--runtime: %w", ErrNotSupported)
+	}
+	if opts.Backend != "" {
+		fmt.Printf("Note: only PowerShell is used on this platform; ignoring backend %s\n", opts.Backend)
+	}
+	servers := settings.Servers
 	cleanServers := stripPorts(servers)
 
 	fmt.Printf("Setting DNS servers: %v (cleaned: %v)\n", servers, cleanServers)
+	printExtras(settings)
 
-	// PowerShell: apply to all UP adapters
-	psServers := "'" + strings.Join(cleanServers, "','") + "'"
-	script := fmt.Sprintf(`Get-NetAdapter | Where-Object {$_.Status -eq 'Up'} | ForEach-Object { Set-DnsClientServerAddress -InterfaceIndex $_.ifIndex -ServerAddresses @(%s) }`, psServers)
+	// PowerShell: apply to the selected adapters
+	psServers := psList(cleanServers)
+	// each adapter reports its own outcome; any failure fails the script
+	script := fmt.Sprintf(`$failed = $false; %s | ForEach-Object { $a = $_; try { Set-DnsClientServerAddress -InterfaceIndex $a.ifIndex -ServerAddresses @(%s) -ErrorAction Stop; Write-Host "$($a.Name): ok" } catch { $failed = $true; Write-Host "$($a.Name): failed: $($_.Exception.Message)" } }`, adapterSelector(opts), psServers)
+	// Windows has one suffix search list for every adapter
+	if len(settings.Search) > 0 {
+		if len(opts.Interfaces) > 0 && !opts.AllInterfaces {
+			fmt.Println("Warning: the suffix search list is machine-wide; --interface does not limit it to the selected adapters")
+		}
+		// keep the list from before the first switch for reset
+		saved := loadSavedSearch()
+		if _, ok := saved[globalSearch]; !ok && !dryRun {
+			prev, err := suffixSearchList()
+			if err != nil {
+				return err
+			}
+			saved[globalSearch] = prev
+			if err := saveSavedSearch(saved); err != nil {
+				return fmt.Errorf("recording the suffix search list: %w", err)
+			}
+		}
+		script += fmt.Sprintf("; Set-DnsClientGlobalSetting -SuffixSearchList @(%s)", psList(settings.Search))
+	}
+	script += "; if ($failed) { exit 1 }"
 
 	if !dryRun {
-		fmt.Println("Using PowerShell to set DNS for all active adapters")
-		out := util.Run(15*time.Second, "powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", script)
+		fmt.Println("Using PowerShell to set DNS for the selected adapters")
+		out := powerShell(15*time.Second, "set DNS servers", script)
+		if out.Stdout != "" {
+			fmt.Println(out.Stdout)
+		}
 		if out.Err != nil {
-			return fmt.Errorf("PowerShell command failed: %v", out.Err)
+			return out.Err
 		}
 		fmt.Printf("Successfully set DNS via PowerShell\n")
 	} else {
-		fmt.Println("[DRY-RUN] Would use PowerShell to set DNS for all active adapters")
+		fmt.Printf("[DRY-RUN] Would run: %s\n", adapterSelector(opts))
 	}
 
 	return nil
 }
 
+// powerShell runs script. A failure names the command by what it was
+// for instead of the whole script; the audit log still has the script.
+func powerShell(timeout time.Duration, what string, script string) util.CmdResult {
+	out := util.Run(timeout, "powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", script)
+	var ce *util.CommandError
+	if errors.As(out.Err, &ce) {
+		short := *ce
+		short.Argv = []string{"powershell", "(" + what + ")"}
+		out.Err = &short
+	}
+	return out
+}
+
+// psList quotes values as a PowerShell array body
+func psList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, "'"+strings.ReplaceAll(v, "'", "''")+"'")
+	}
+	return strings.Join(quoted, ",")
+}
+
+// ActiveBackend names the service that manages DNS on this host
+func ActiveBackend() string {
+	return "powershell"
+}
+
 func Status() (map[string][]string, error) {
 	res := map[string][]string{}
 	script := `Get-DnsClientServerAddress | Where-Object {$_.ServerAddresses} | Select-Object InterfaceAlias,ServerAddresses | ConvertTo-Json`
-	out := util.Run(10*time.Second, "powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", script)
+	out := powerShell(10*time.Second, "read DNS servers", script)
 	if out.Err != nil {
 		return res, out.Err
 	}
@@ -66,31 +128,96 @@ func Status() (map[string][]string, error) {
 	return res, nil
 }
 
+// Details reports servers per adapter; the global suffix search list is
+// attached to each of them
+func Details() ([]Link, error) {
+	st, err := Status()
+	if err != nil {
+		return nil, err
+	}
+	search, _ := suffixSearchList()
+	var out []Link
+	for iface, servers := range st {
+		out = append(out, Link{Iface: iface, Servers: servers, Domains: search})
+	}
+	sortLinks(out)
+	return out, nil
+}
+
+// globalSearch keys the machine-wide suffix search list in the saved
+// search domains
+const globalSearch = "global"
+
+// suffixSearchList reads the machine-wide suffix search list
+func suffixSearchList() ([]string, error) {
+	script := `(Get-DnsClientGlobalSetting).SuffixSearchList | ConvertTo-Json`
+	out := powerShell(10*time.Second, "read the suffix search list", script)
+	if out.Err != nil {
+		return nil, out.Err
+	}
+	var search []string
+	// a single suffix is serialized as a string, not an array
+	if jsonUnmarshal(out.Stdout, &search) != nil {
+		var one string
+		if jsonUnmarshal(out.Stdout, &one) == nil && one != "" {
+			search = []string{one}
+		}
+	}
+	return search, nil
+}
+
 // minimal JSON helper (for PowerShell output)
 func jsonUnmarshal(s string, v interface{}) error {
 	dec := json.NewDecoder(strings.NewReader(s))
 	return dec.Decode(v)
 }
 
-func ResetToDHCP(dryRun bool) error {
+func ResetToDHCP(opts Options) error {
+	dryRun := opts.DryRun
+	if opts.Mode == ModeRuntime {
+		return fmt.Errorf("--runtime: %w", ErrNotSupported)
+	}
+	if opts.Backend != "" {
+		fmt.Printf("Note: only PowerShell is used on this platform; ignoring backend %s\n", opts.Backend)
+	}
 	fmt.Println("Resetting DNS to DHCP defaults")
 
-	// PowerShell: reset all adapters to DHCP DNS
+	// PowerShell: reset the selected adapters to DHCP DNS
 	// Windows: use -ResetServerAddresses to restore DHCP DNS
-	script := `Get-NetAdapter | Where-Object {$_.Status -eq 'Up'} | ForEach-Object { 
+	script := adapterSelector(opts) + ` | ForEach-Object { 
 		Write-Host "Resetting DNS for adapter: $($_.Name)"
 		Set-DnsClientServerAddress -InterfaceIndex $_.ifIndex -ResetServerAddresses
 	}`
+	// the suffix search list is machine-wide: only the list switch
+	// --search replaced is put back, and only when every adapter is reset
+	saved := loadSavedSearch()
+	prev, restore := saved[globalSearch]
+	if restore && len(opts.Interfaces) > 0 && !opts.AllInterfaces {
+		fmt.Println("Note: the machine-wide suffix search list set by switch is kept; reset without --interface to restore it")
+		restore = false
+	}
+	if restore {
+		script += fmt.Sprintf("; Set-DnsClientGlobalSetting -SuffixSearchList @(%s)", psList(prev))
+	}
 
 	if !dryRun {
-		fmt.Println("Using PowerShell to reset DNS for all active adapters")
-		out := util.Run(15*time.Second, "powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", script)
+		fmt.Println("Using PowerShell to reset DNS for the selected adapters")
+		out := powerShell(15*time.Second, "reset DNS servers", script)
 		if out.Err != nil {
-			return fmt.Errorf("PowerShell reset command failed: %v", out.Err)
+			return out.Err
+		}
+		if restore {
+			delete(saved, globalSearch)
+			if err := saveSavedSearch(saved); err != nil {
+				return fmt.Errorf("recording the suffix search list: %w", err)
+			}
 		}
 		fmt.Printf("Successfully reset DNS via PowerShell\n")
 	} else {
-		fmt.Println("[DRY-RUN] Would use PowerShell to reset DNS for all active adapters")
+		fmt.Printf("[DRY-RUN] Would reset DNS for: %s\n", adapterSelector(opts))
+		if restore {
+			fmt.Printf("[DRY-RUN] Would restore the suffix search list: %v\n", prev)
+		}
 	}
 
 	return nil
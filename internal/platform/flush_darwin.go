@@ -0,0 +1,33 @@
+//go:build darwin
+
+package platform
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+// FlushCaches empties the directory service cache and mDNSResponder's
+func FlushCaches(dryRun bool) []Flushed {
+	cmds := []struct {
+		cache string
+		args  []string
+	}{
+		{"dscacheutil", []string{"dscacheutil", "-flushcache"}},
+		{"mDNSResponder", []string{"killall", "-HUP", "mDNSResponder"}},
+	}
+	var out []Flushed
+	for _, c := range cmds {
+		if dryRun {
+			fmt.Printf("[DRY-RUN] Would run: %s\n", strings.Join(c.args, " "))
+			out = append(out, Flushed{Cache: c.cache})
+			continue
+		}
+		res := util.Run(5*time.Second, c.args[0], c.args[1:]...)
+		out = append(out, Flushed{Cache: c.cache, Err: res.Err})
+	}
+	return out
+}
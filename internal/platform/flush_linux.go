@@ -0,0 +1,50 @@
+//go:build linux
+
+package platform
+
+// Local caches that keep answers from the previous servers after a switch
+
+import (
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+// dnsCache is a caching service and the command that empties its cache
+type dnsCache struct {
+	name  string
+	unit  string // systemd unit that must be active
+	flush []string
+}
+
+var dnsCaches = []dnsCache{
+	{name: "systemd-resolved", unit: "systemd-resolved", flush: []string{"resolvectl", "flush-caches"}},
+	{name: "nscd", unit: "nscd", flush: []string{"nscd", "-i", "hosts"}},
+	// SIGHUP empties the cache without re-reading the configuration
+	// (unbound-control needs remote-control enabled in unbound.conf)
+	{name: "dnsmasq", unit: "dnsmasq", flush: []string{"systemctl", "kill", "--signal=HUP", "dnsmasq"}},
+	{name: "unbound", unit: "unbound", flush: []string{"unbound-control", "flush_zone", "."}},
+}
+
+type cacheFlusher struct {
+	run util.Runner
+}
+
+var flusher = cacheFlusher{run: util.ExecRunner{}}
+
+// FlushCaches empties every running local DNS cache
+func FlushCaches(dryRun bool) []Flushed {
+	return flusher.flush(dryRun)
+}
+
+func (f cacheFlusher) flush(dryRun bool) []Flushed {
+	var out []Flushed
+	for _, c := range dnsCaches {
+		if f.run.Run(3*time.Second, "systemctl", "is-active", "--quiet", c.unit).Err != nil {
+			continue
+		}
+		err := runCmd(f.run, dryRun, 5*time.Second, c.flush[0], c.flush[1:]...)
+		out = append(out, Flushed{Cache: c.name, Err: err})
+	}
+	return out
+}
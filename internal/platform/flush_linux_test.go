@@ -0,0 +1,43 @@
+//go:build linux
+
+package platform
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"dns-helper/internal/util"
+)
+
+func TestFlushOnlyRunningCaches(t *testing.T) {
+	inactive := util.CmdResult{Err: errors.New("exit status 3")}
+	f := &fakeRunner{outputs: map[string]util.CmdResult{
+		"systemctl is-active --quiet nscd":    inactive,
+		"systemctl is-active --quiet unbound": inactive,
+		"systemctl kill --signal=HUP dnsmasq": {Err: errors.New("exit status 1"), Stderr: "Access denied"},
+	}}
+	got := cacheFlusher{run: f}.flush(false)
+	if len(got) != 2 || got[0].Cache != "systemd-resolved" || got[0].Err != nil {
+		t.Fatalf("Unexpected results: %+v", got)
+	}
+	if got[1].Cache != "dnsmasq" || got[1].Err == nil || !strings.Contains(got[1].Err.Error(), "Access denied") {
+		t.Errorf("Expected the dnsmasq failure to be reported, got %+v", got[1])
+	}
+	if len(f.ran("nscd")) != 0 || len(f.ran("unbound-control")) != 0 {
+		t.Errorf("Stopped caches must not be flushed, ran %q", f.calls)
+	}
+}
+
+func TestFlushDryRun(t *testing.T) {
+	f := &fakeRunner{outputs: map[string]util.CmdResult{}}
+	got := cacheFlusher{run: f}.flush(true)
+	if len(got) != len(dnsCaches) {
+		t.Errorf("Expected every running cache, got %+v", got)
+	}
+	for _, c := range f.calls {
+		if !strings.HasPrefix(c, "systemctl is-active") {
+			t.Errorf("Dry run ran %q", c)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package platform
+
+import (
+	"fmt"
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+// FlushCaches empties the DNS Client service cache
+func FlushCaches(dryRun bool) []Flushed {
+	if dryRun {
+		fmt.Println("[DRY-RUN] Would run: Clear-DnsClientCache")
+		return []Flushed{{Cache: "DNS Client"}}
+	}
+	out := util.Run(10*time.Second, "powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "Clear-DnsClientCache")
+	return []Flushed{{Cache: "DNS Client", Err: out.Err}}
+}
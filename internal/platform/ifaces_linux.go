@@ -0,0 +1,122 @@
+//go:build linux
+
+package platform
+
+// Native interface enumeration from sysfs and procfs, so no "ip | awk"
+// pipeline is needed to find the interfaces to configure.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// replaced in tests
+var (
+	sysClassNet   = "/sys/class/net"
+	procNetRoute  = "/proc/net/route"
+	procIPv6Route = "/proc/net/ipv6_route"
+)
+
+// Interfaces lists network interfaces that can carry DNS configuration:
+// everything except loopback and interfaces that are administratively down.
+func Interfaces() ([]string, error) {
+	entries, err := os.ReadDir(sysClassNet)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		name := e.Name()
+		if name == "lo" {
+			continue
+		}
+		state, _ := os.ReadFile(filepath.Join(sysClassNet, name, "operstate"))
+		// tunnels report "unknown" while up
+		if strings.TrimSpace(string(state)) == "down" {
+			continue
+		}
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// defaultIface returns the interface of the lowest-metric default route,
+// preferring IPv4 and falling back to IPv6.
+func defaultIface() string {
+	if iface := defaultIPv4Iface(); iface != "" {
+		return iface
+	}
+	return defaultIPv6Iface()
+}
+
+func defaultIPv4Iface() string {
+	data, err := os.ReadFile(procNetRoute)
+	if err != nil {
+		return ""
+	}
+	best, bestMetric := "", -1
+	// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+	for _, l := range strings.Split(string(data), "\n")[1:] {
+		f := strings.Fields(l)
+		if len(f) < 8 || f[1] != "00000000" || f[7] != "00000000" {
+			continue
+		}
+		metric, _ := strconv.Atoi(f[6])
+		if bestMetric < 0 || metric < bestMetric {
+			best, bestMetric = f[0], metric
+		}
+	}
+	return best
+}
+
+func defaultIPv6Iface() string {
+	data, err := os.ReadFile(procIPv6Route)
+	if err != nil {
+		return ""
+	}
+	best, bestMetric := "", int64(-1)
+	// dest prefix src srcprefix nexthop metric refcnt use flags iface
+	for _, l := range strings.Split(string(data), "\n") {
+		f := strings.Fields(l)
+		if len(f) < 10 || f[0] != strings.Repeat("0", 32) || f[1] != "00" || f[9] == "lo" {
+			continue
+		}
+		metric, _ := strconv.ParseInt(f[5], 16, 64)
+		if bestMetric < 0 || metric < bestMetric {
+			best, bestMetric = f[9], metric
+		}
+	}
+	return best
+}
+
+// targetIfaces resolves the interfaces selected by opts
+func targetIfaces(opts Options) ([]string, error) {
+	all, err := Interfaces()
+	if err != nil && (opts.AllInterfaces || len(opts.Interfaces) > 0) {
+		return nil, fmt.Errorf("listing interfaces: %v", err)
+	}
+	if opts.AllInterfaces {
+		return all, nil
+	}
+	if len(opts.Interfaces) > 0 {
+		known := map[string]bool{}
+		for _, i := range all {
+			known[i] = true
+		}
+		for _, i := range opts.Interfaces {
+			if !known[i] {
+				return nil, fmt.Errorf("unknown or down interface: %s (available: %s)", i, strings.Join(all, ", "))
+			}
+		}
+		return opts.Interfaces, nil
+	}
+	if iface := defaultIface(); iface != "" {
+		return []string{iface}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,86 @@
+//go:build linux
+
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func fakeSysfs(t *testing.T, states map[string]string) {
+	dir := t.TempDir()
+	for name, state := range states {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "operstate"), []byte(state+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orig := sysClassNet
+	sysClassNet = dir
+	t.Cleanup(func() { sysClassNet = orig })
+}
+
+func fakeProcFile(t *testing.T, target *string, content string) {
+	p := filepath.Join(t.TempDir(), "route")
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	orig := *target
+	*target = p
+	t.Cleanup(func() { *target = orig })
+}
+
+const procRoute = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wlp3s0	00000000	0101A8C0	0003	0	0	600	00000000	0	0	0
+tun0	00000000	0100080A	0003	0	0	50	00000000	0	0	0
+wlp3s0	0001A8C0	00000000	0001	0	0	600	00FFFFFF	0	0	0
+`
+
+func TestInterfacesSkipsLoopbackAndDown(t *testing.T) {
+	fakeSysfs(t, map[string]string{"lo": "unknown", "eth0": "up", "tun0": "unknown", "eth1": "down"})
+
+	got, err := Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "eth0" || got[1] != "tun0" {
+		t.Errorf("Expected [eth0 tun0], got %v", got)
+	}
+}
+
+func TestDefaultIfaceLowestMetric(t *testing.T) {
+	fakeProcFile(t, &procNetRoute, procRoute)
+	if got := defaultIface(); got != "tun0" {
+		t.Errorf("Expected tun0 (metric 50), got %q", got)
+	}
+}
+
+func TestDefaultIfaceIPv6Fallback(t *testing.T) {
+	fakeProcFile(t, &procNetRoute, "Iface\tDestination\n")
+	fakeProcFile(t, &procIPv6Route,
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003 eth0\n")
+	if got := defaultIface(); got != "eth0" {
+		t.Errorf("Expected eth0 from the IPv6 table, got %q", got)
+	}
+}
+
+func TestTargetIfaces(t *testing.T) {
+	fakeSysfs(t, map[string]string{"eth0": "up", "tun0": "unknown"})
+	fakeProcFile(t, &procNetRoute, procRoute)
+
+	if got, _ := targetIfaces(Options{}); len(got) != 1 || got[0] != "tun0" {
+		t.Errorf("Expected default route interface, got %v", got)
+	}
+	if got, _ := targetIfaces(Options{AllInterfaces: true}); len(got) != 2 {
+		t.Errorf("Expected every interface, got %v", got)
+	}
+	if got, _ := targetIfaces(Options{Interfaces: []string{"eth0"}}); len(got) != 1 || got[0] != "eth0" {
+		t.Errorf("Expected explicit interface, got %v", got)
+	}
+	if _, err := targetIfaces(Options{Interfaces: []string{"wg0"}}); err == nil {
+		t.Error("Expected error for an unknown interface")
+	}
+}
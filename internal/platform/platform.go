@@ -0,0 +1,182 @@
+package platform
+
+// Types shared by every platform implementation
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotSupported is returned for operations this platform cannot perform
+var ErrNotSupported = errors.New("not supported on this platform")
+
+// ErrPrivileges is returned by CheckPrivileges when a change would fail
+// for lack of privileges
+var ErrPrivileges = errors.New("insufficient privileges")
+
+// Options selects where and how DNS changes are applied
+type Options struct {
+	DryRun        bool
+	Interfaces    []string // explicit interfaces; empty means the platform default
+	AllInterfaces bool
+	Force         bool // overwrite resolv.conf even when another service manages it
+	Mode          Mode
+	Backend       string // backend to try first, by name (Linux)
+}
+
+// Change is what a switch, reset or undo is about to do, so
+// CheckPrivileges can check every operation it needs
+type Change struct {
+	Apply *Settings // settings applied to links, nil when none are
+	Reset bool      // links go back to DHCP
+}
+
+// stripPorts drops the port of "host:port" and "[v6]:port" servers and
+// the brackets of "[v6]"; a bare IPv6 address is kept as it is
+func stripPorts(servers []string) []string {
+	out := make([]string, 0, len(servers))
+	for _, s := range servers {
+		if net.ParseIP(s) != nil {
+			out = append(out, s)
+			continue
+		}
+		if host, _, err := net.SplitHostPort(s); err == nil && host != "" {
+			out = append(out, host)
+			continue
+		}
+		out = append(out, strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	}
+	return out
+}
+
+// Mode chooses between persistent and runtime changes
+type Mode int
+
+const (
+	ModeAuto    Mode = iota // whatever the preferred backend does
+	ModePersist             // only backends whose changes survive reboots and lease renewals
+	ModeRuntime             // only backends whose changes are lost on reboot
+)
+
+// allows reports whether a backend with durability d may be used in mode m
+func (m Mode) allows(d Durability) bool {
+	switch m {
+	case ModePersist:
+		return d.Reboot && d.Renewal
+	case ModeRuntime:
+		return !d.Reboot
+	}
+	return true
+}
+
+func (m Mode) flag() string {
+	switch m {
+	case ModePersist:
+		return "--persist"
+	case ModeRuntime:
+		return "--runtime"
+	}
+	return ""
+}
+
+// Durability says which events a DNS change survives
+type Durability struct {
+	Reboot  bool
+	Renewal bool // a DHCP lease renewal or router advertisement
+}
+
+var (
+	persistent  = Durability{Reboot: true, Renewal: true}
+	runtimeOnly = Durability{}
+	untilReboot = Durability{Renewal: true}
+)
+
+func (d Durability) String() string {
+	switch {
+	case d.Reboot && d.Renewal:
+		return "survives reboot and DHCP renewal"
+	case d.Reboot:
+		return "survives reboot, lost on DHCP renewal"
+	case d.Renewal:
+		return "survives DHCP renewal, lost on reboot"
+	}
+	return "lost on reboot and DHCP renewal"
+}
+
+// Applied records a change dns-helper made, so status can report what it
+// survives and whether something else changed it since
+type Applied struct {
+	Backend    string     `json:"backend"`
+	Durability Durability `json:"durability"`
+	Servers    []string   `json:"servers"`
+	Time       time.Time  `json:"time"`
+}
+
+// Current reports whether servers are still the ones dns-helper set
+func (a Applied) Current(servers []string) bool {
+	x := append([]string(nil), a.Servers...)
+	y := append([]string(nil), servers...)
+	sort.Strings(x)
+	sort.Strings(y)
+	return slices.Equal(x, y)
+}
+
+// Settings is the resolver configuration applied to an interface. Empty
+// Search or Options leave the current values untouched.
+type Settings struct {
+	Servers []string
+	Search  []string // search domains
+	Options []string // resolver options such as "ndots:5" or "rotate"
+}
+
+// Link is the DNS configuration of a single network interface
+type Link struct {
+	Iface   string
+	Servers []string
+	Domains []string // search domains, and routing domains prefixed with "~"
+	Options []string // resolver options, where the backend reports them
+	Applied *Applied // set when dns-helper configured this link
+}
+
+// Search returns the search domains of the link, without routing domains
+func (l Link) Search() []string {
+	var out []string
+	for _, d := range l.Domains {
+		if len(d) > 0 && d[0] != '~' {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Route sends queries for Domain and its subdomains to the servers of Iface
+type Route struct {
+	Iface   string
+	Domain  string
+	Servers []string
+}
+
+// Flushed is the outcome of emptying one local DNS cache
+type Flushed struct {
+	Cache string
+	Err   error
+}
+
+// printExtras reports the search domains and options about to be applied
+func printExtras(s Settings) {
+	if len(s.Search) > 0 {
+		fmt.Printf("Setting search domains: %v\n", s.Search)
+	}
+	if len(s.Options) > 0 {
+		fmt.Printf("Setting resolver options: %v\n", s.Options)
+	}
+}
+
+func sortLinks(links []Link) {
+	sort.Slice(links, func(i, j int) bool { return links[i].Iface < links[j].Iface })
+}
@@ -1,7 +1,6 @@
 package platform
 
 import (
-	"strings"
 	"testing"
 )
 
@@ -37,19 +36,6 @@ var testStatus = map[string][]string{
 	"VPN":      {},
 }
 
-// testStripPorts function for testing - matches the behavior in platform files
-func testStripPorts(servers []string) []string {
-	out := make([]string, 0, len(servers))
-	for _, s := range servers {
-		if i := strings.Index(s, ":"); i > 0 {
-			out = append(out, s[:i])
-		} else {
-			out = append(out, s)
-		}
-	}
-	return out
-}
-
 func TestStripPorts(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -76,14 +62,16 @@ func TestStripPorts(t *testing.T) {
 			input:    []string{},
 			expected: []string{},
 		},
-		// IPv6 test case removed - platform stripPorts function uses strings.Index
-		// which doesn't handle IPv6 addresses correctly
+		{
+			name:     "ipv6",
+			input:    []string{"2606:4700::1111", "[2606:4700::1001]:53", "[::1]", "fe80::1%eth0"},
+			expected: []string{"2606:4700::1111", "2606:4700::1001", "::1", "fe80::1%eth0"},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			// Use the test stripPorts function
-			result := testStripPorts(tc.input)
+			result := stripPorts(tc.input)
 
 			if len(result) != len(tc.expected) {
 				t.Errorf("Expected %d results, got %d", len(tc.expected), len(result))
@@ -177,7 +165,7 @@ func TestPortStrippingEdgeCases(t *testing.T) {
 	}{
 		{
 			input:    []string{":53", ":", ""},
-			expected: []string{":53", ":", ""}, // strings.Index returns -1 for these cases
+			expected: []string{":53", ":", ""}, // no host to keep: left as given
 			desc:     "malformed addresses",
 		},
 		{
@@ -194,7 +182,7 @@ func TestPortStrippingEdgeCases(t *testing.T) {
 
 	for _, tc := range edgeCases {
 		t.Run(tc.desc, func(t *testing.T) {
-			result := testStripPorts(tc.input)
+			result := stripPorts(tc.input)
 
 			if len(result) != len(tc.expected) {
 				t.Errorf("Expected %d results, got %d", len(tc.expected), len(result))
@@ -209,3 +197,37 @@ func TestPortStrippingEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestModeAllows(t *testing.T) {
+	cases := []struct {
+		mode Mode
+		d    Durability
+		want bool
+	}{
+		{ModeAuto, runtimeOnly, true},
+		{ModePersist, persistent, true},
+		{ModePersist, untilReboot, false},
+		{ModePersist, Durability{Reboot: true}, false},
+		{ModeRuntime, runtimeOnly, true},
+		{ModeRuntime, untilReboot, true},
+		{ModeRuntime, persistent, false},
+	}
+	for _, c := range cases {
+		if got := c.mode.allows(c.d); got != c.want {
+			t.Errorf("Mode %d allows %+v = %v, expected %v", c.mode, c.d, got, c.want)
+		}
+	}
+	if untilReboot.String() != "survives DHCP renewal, lost on reboot" {
+		t.Errorf("Unexpected description: %s", untilReboot)
+	}
+}
+
+func TestAppliedCurrent(t *testing.T) {
+	a := Applied{Servers: []string{"1.1.1.1", "1.0.0.1"}}
+	if !a.Current([]string{"1.0.0.1", "1.1.1.1"}) {
+		t.Error("Order must not matter")
+	}
+	if a.Current([]string{"192.168.1.1"}) {
+		t.Error("Other servers must be reported as changed")
+	}
+}
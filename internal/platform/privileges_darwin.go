@@ -0,0 +1,17 @@
+//go:build darwin
+
+package platform
+
+import (
+	"fmt"
+	"os"
+)
+
+// CheckPrivileges reports whether networksetup may change DNS settings:
+// it needs root
+func CheckPrivileges(opts Options, c Change) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("%w: networksetup needs root", ErrPrivileges)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+//go:build linux
+
+package platform
+
+// Privilege preflight. Backends that write under /etc or reload system
+// services need root. systemd-resolved also accepts CAP_NET_ADMIN, and
+// it and NetworkManager authorize other callers through polkit, which
+// may ask for a password.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+// authorizer is implemented by backends whose service decides itself who
+// may change DNS, so they can work without root
+type authorizer interface {
+	// authorization lists the polkit actions the service asks about for
+	// change c; netAdmin reports whether CAP_NET_ADMIN is enough without
+	// asking
+	authorization(c Change) (polkitActions []string, netAdmin bool)
+}
+
+func (resolvedBackend) authorization(c Change) ([]string, bool) {
+	return resolvedActions(c), true
+}
+
+func (resolvectlBackend) authorization(c Change) ([]string, bool) {
+	return resolvedActions(c), true
+}
+
+// resolvedActions follows the calls of Apply (SetLinkDNS, and
+// SetLinkDomains for search domains) and Reset (RevertLink)
+func resolvedActions(c Change) []string {
+	var out []string
+	if c.Apply != nil {
+		out = append(out, "org.freedesktop.resolve1.set-dns-servers")
+		if len(c.Apply.Search) > 0 {
+			out = append(out, "org.freedesktop.resolve1.set-domains")
+		}
+	}
+	if c.Reset {
+		out = append(out, "org.freedesktop.resolve1.revert")
+	}
+	return out
+}
+
+// Both Apply and Reset of the profile variant modify the connection and
+// reapply it to the device; the device variant only reapplies
+func (b nmBackend) authorization(c Change) ([]string, bool) {
+	if c.Apply == nil && !c.Reset {
+		return nil, false
+	}
+	if b.device {
+		return []string{"org.freedesktop.NetworkManager.network-control"}, false
+	}
+	return []string{"org.freedesktop.NetworkManager.settings.modify.system", "org.freedesktop.NetworkManager.network-control"}, false
+}
+
+// capNetAdmin is the bit of CAP_NET_ADMIN in the capability sets
+const capNetAdmin = 12
+
+type privileges struct {
+	run    util.Runner
+	euid   func() int
+	status string // /proc/self/status, for the effective capabilities
+}
+
+var privs = privileges{run: util.ExecRunner{}, euid: os.Geteuid, status: "/proc/self/status"}
+
+// CheckPrivileges reports whether this process may make change c where
+// opts direct it, before anything is touched. A missing privilege is an
+// ErrPrivileges naming the backend that needs it.
+func CheckPrivileges(opts Options, c Change) error {
+	return privs.check(opts, c)
+}
+
+func (p privileges) check(opts Options, c Change) error {
+	if p.euid() == 0 {
+		return nil
+	}
+	name, b := plannedBackend(opts)
+	return p.allowed(name, b, c)
+}
+
+// allowed reports whether a caller without root may make change c with
+// backend b. Every action the change needs is checked, so it cannot fail
+// halfway.
+func (p privileges) allowed(name string, b backend, c Change) error {
+	a, ok := b.(authorizer)
+	if !ok {
+		return fmt.Errorf("%w: %s needs root", ErrPrivileges, name)
+	}
+	actions, netAdmin := a.authorization(c)
+	if netAdmin && p.netAdmin() {
+		return nil
+	}
+	challenge := false
+	for _, action := range actions {
+		switch p.polkit(action) {
+		case authNo:
+			return fmt.Errorf("%w: polkit does not allow %s for this user (%s)", ErrPrivileges, name, action)
+		case authChallenge:
+			challenge = true
+		}
+	}
+	if challenge {
+		fmt.Printf("Note: not running as root; %s will ask polkit for authentication\n", name)
+	}
+	return nil
+}
+
+// plannedBackend names the backend a change with opts would use first,
+// and returns it when it is a per-interface backend
+func plannedBackend(opts Options) (string, backend) {
+	if l, ok := activeLocalResolver(); ok {
+		return l.name, nil
+	}
+	cands, _, err := orderedCandidates(opts.Mode, opts.Backend)
+	if err != nil || len(cands) == 0 {
+		// SwitchAll reports the error; the fallback edits resolv.conf
+		return resolvConfPath, nil
+	}
+	return cands[0].Name(), cands[0]
+}
+
+// netAdmin reports whether CAP_NET_ADMIN is in the effective set
+func (p privileges) netAdmin() bool {
+	f, err := os.Open(p.status)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		hex, ok := strings.CutPrefix(sc.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(hex), 16, 64)
+		return err == nil && caps&(1<<capNetAdmin) != 0
+	}
+	return false
+}
+
+type authorization int
+
+const (
+	authUnknown   authorization = iota // pkcheck is missing or failed
+	authYes                            // allowed without asking
+	authChallenge                      // allowed after authenticating
+	authNo
+)
+
+// polkit asks pkcheck whether this process is allowed action
+func (p privileges) polkit(action string) authorization {
+	out := p.run.Run(5*time.Second, "pkcheck", "--action-id", action, "--process", strconv.Itoa(os.Getpid()))
+	if out.Err == nil {
+		return authYes
+	}
+	var ce *util.CommandError
+	if !errors.As(out.Err, &ce) {
+		return authUnknown
+	}
+	// pkcheck exits 1 when not authorized, 2 when authentication is
+	// required and 3 when the dialog was dismissed
+	switch ce.ExitCode {
+	case 1:
+		return authNo
+	case 2, 3:
+		return authChallenge
+	}
+	return authUnknown
+}
@@ -0,0 +1,137 @@
+//go:build linux
+
+package platform
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"dns-helper/internal/util"
+)
+
+func testPrivileges(t *testing.T, capEff string, pkcheck util.CmdResult) (privileges, *fakeRunner) {
+	t.Helper()
+	status := filepath.Join(t.TempDir(), "status")
+	data := "Name:\tdns-helper\nCapInh:\t0000000000000000\nCapEff:\t" + capEff + "\n"
+	if err := os.WriteFile(status, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRunner{outputs: map[string]util.CmdResult{}}
+	for _, action := range polkitActions {
+		f.outputs[pkcheckCmd(action)] = pkcheck
+	}
+	return privileges{run: f, euid: func() int { return 1000 }, status: status}, f
+}
+
+var polkitActions = []string{
+	"org.freedesktop.resolve1.set-dns-servers",
+	"org.freedesktop.resolve1.set-domains",
+	"org.freedesktop.resolve1.revert",
+	"org.freedesktop.NetworkManager.settings.modify.system",
+	"org.freedesktop.NetworkManager.network-control",
+}
+
+func pkcheckCmd(action string) string {
+	return "pkcheck --action-id " + action + " --process " + strconv.Itoa(os.Getpid())
+}
+
+var switchChange = Change{Apply: &Settings{Servers: []string{"1.1.1.1"}}}
+
+func pkcheckExit(code int) util.CmdResult {
+	return util.CmdResult{Err: &util.CommandError{Argv: []string{"pkcheck"}, ExitCode: code, Err: errors.New("exit status")}}
+}
+
+func TestPrivilegesFileBackendsNeedRoot(t *testing.T) {
+	p, f := testPrivileges(t, "000001ffffffffff", util.CmdResult{})
+	err := p.allowed("netplan", netplanBackend{}, switchChange)
+	if !errors.Is(err, ErrPrivileges) {
+		t.Errorf("Expected ErrPrivileges for netplan, got %v", err)
+	}
+	if len(f.calls) != 0 {
+		t.Errorf("polkit is not asked for file backends, ran %q", f.calls)
+	}
+}
+
+func TestPrivilegesNetAdminIsEnoughForResolved(t *testing.T) {
+	p, f := testPrivileges(t, "0000000000001000", pkcheckExit(1))
+	if err := p.allowed("systemd-resolved", resolvedBackend{}, switchChange); err != nil {
+		t.Errorf("CAP_NET_ADMIN should be enough for systemd-resolved: %v", err)
+	}
+	if len(f.calls) != 0 {
+		t.Errorf("polkit should not be asked with CAP_NET_ADMIN, ran %q", f.calls)
+	}
+	if err := p.allowed("NetworkManager", nmBackend{}, switchChange); !errors.Is(err, ErrPrivileges) {
+		t.Errorf("NetworkManager checks polkit even with CAP_NET_ADMIN, got %v", err)
+	}
+}
+
+func TestPrivilegesPolkit(t *testing.T) {
+	tests := []struct {
+		name    string
+		pkcheck util.CmdResult
+		denied  bool
+	}{
+		{"authorized", util.CmdResult{}, false},
+		{"not authorized", pkcheckExit(1), true},
+		{"needs authentication", pkcheckExit(2), false},
+		{"pkcheck missing", pkcheckExit(-1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := testPrivileges(t, "0000000000000000", tt.pkcheck)
+			err := p.allowed("systemd-resolved", resolvedBackend{}, switchChange)
+			if denied := errors.Is(err, ErrPrivileges); denied != tt.denied {
+				t.Errorf("Expected denied=%v, got %v", tt.denied, err)
+			}
+		})
+	}
+}
+
+func TestPrivilegesChecksEveryAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       backend
+		change  Change
+		actions []string
+	}{
+		{"resolved switch", resolvedBackend{}, switchChange, []string{"org.freedesktop.resolve1.set-dns-servers"}},
+		{"resolved switch with search", resolvedBackend{}, Change{Apply: &Settings{Search: []string{"lan"}}},
+			[]string{"org.freedesktop.resolve1.set-dns-servers", "org.freedesktop.resolve1.set-domains"}},
+		{"resolvectl reset", resolvectlBackend{}, Change{Reset: true}, []string{"org.freedesktop.resolve1.revert"}},
+		{"NetworkManager profile", nmBackend{}, Change{Reset: true},
+			[]string{"org.freedesktop.NetworkManager.settings.modify.system", "org.freedesktop.NetworkManager.network-control"}},
+		{"NetworkManager device", nmBackend{device: true}, switchChange, []string{"org.freedesktop.NetworkManager.network-control"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, f := testPrivileges(t, "0000000000000000", util.CmdResult{})
+			if err := p.allowed("backend", tt.b, tt.change); err != nil {
+				t.Fatal(err)
+			}
+			var expected []string
+			for _, a := range tt.actions {
+				expected = append(expected, pkcheckCmd(a))
+			}
+			if strings.Join(f.calls, "\n") != strings.Join(expected, "\n") {
+				t.Errorf("Expected polkit checks:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(f.calls, "\n"))
+			}
+
+			// refusing the last action refuses the change
+			f.outputs[expected[len(expected)-1]] = pkcheckExit(1)
+			if err := p.allowed("backend", tt.b, tt.change); !errors.Is(err, ErrPrivileges) {
+				t.Errorf("Expected ErrPrivileges when %s is refused, got %v", tt.actions[len(tt.actions)-1], err)
+			}
+		})
+	}
+}
+
+func TestPrivilegesRoot(t *testing.T) {
+	p := privileges{euid: func() int { return 0 }}
+	if err := p.check(Options{}, switchChange); err != nil {
+		t.Errorf("root needs no further checks: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package platform
+
+import (
+	"fmt"
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+// CheckPrivileges reports whether Set-DnsClientServerAddress may run: it
+// needs an elevated Administrator, which "net session" requires as well
+func CheckPrivileges(opts Options, c Change) error {
+	if out := util.Run(5*time.Second, "net", "session"); out.Err != nil {
+		return fmt.Errorf("%w: changing DNS needs an elevated Administrator prompt", ErrPrivileges)
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+//go:build linux
+
+package platform
+
+// Split DNS through systemd-resolved routing domains. A domain prefixed
+// with "~" on a link sends matching queries to that link's servers only.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+type resolvedRoutes struct {
+	run util.Runner
+}
+
+var routes = resolvedRoutes{run: util.ExecRunner{}}
+
+func AddRoute(domain string, servers []string, iface string, dryRun bool) error {
+	return routes.add(domain, stripPorts(servers), iface, dryRun)
+}
+
+func RemoveRoute(domain string, iface string, dryRun bool) error {
+	return routes.remove(domain, iface, dryRun)
+}
+
+func Routes() ([]Route, error) {
+	return routes.list()
+}
+
+// Links reports per-link DNS servers and domains, over D-Bus when possible
+func Links() ([]Link, error) {
+	if links, err := resolvedBus.links(); err == nil {
+		return links, nil
+	}
+	return routes.links()
+}
+
+// links merges "resolvectl dns" and "resolvectl domain" per link
+func (r resolvedRoutes) links() ([]Link, error) {
+	dns := r.run.Run(5*time.Second, "resolvectl", "dns")
+	if dns.Err != nil {
+		return nil, dns.Err
+	}
+	doms := r.run.Run(5*time.Second, "resolvectl", "domain")
+	if doms.Err != nil {
+		return nil, doms.Err
+	}
+	var out []Link
+	index := map[string]int{}
+	for iface, servers := range parseLinkLines(dns.Stdout) {
+		index[iface] = len(out)
+		out = append(out, Link{Iface: iface, Servers: servers})
+	}
+	for iface, domains := range parseLinkLines(doms.Stdout) {
+		i, ok := index[iface]
+		if !ok {
+			i = len(out)
+			index[iface] = i
+			out = append(out, Link{Iface: iface})
+		}
+		out[i].Domains = domains
+	}
+	sortLinks(out)
+	return out, nil
+}
+
+// parseLinkLines parses "Link 3 (wlp3s0): a b c" lines. The interface name
+// is taken from the parentheses so IPv6 addresses are left intact.
+func parseLinkLines(s string) map[string][]string {
+	out := map[string][]string{}
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if !strings.HasPrefix(l, "Link ") {
+			continue
+		}
+		open := strings.Index(l, "(")
+		end := strings.Index(l, "):")
+		if open < 0 || end < open {
+			continue
+		}
+		out[l[open+1:end]] = strings.Fields(l[end+2:])
+	}
+	return out
+}
+
+func (r resolvedRoutes) list() ([]Route, error) {
+	links, err := r.links()
+	if err != nil {
+		return nil, err
+	}
+	var out []Route
+	for _, l := range links {
+		for _, d := range l.Domains {
+			if strings.HasPrefix(d, "~") && d != "~." {
+				out = append(out, Route{Iface: l.Iface, Domain: strings.TrimPrefix(d, "~"), Servers: l.Servers})
+			}
+		}
+	}
+	return out, nil
+}
+
+// routeIface is the link the kernel uses to reach server; queries for a
+// routing domain are only sent to servers configured on the same link.
+func (r resolvedRoutes) routeIface(server string) string {
+	out := r.run.Run(3*time.Second, "ip", "route", "get", server)
+	if out.Err != nil {
+		return ""
+	}
+	return devField(out.Stdout)
+}
+
+func (r resolvedRoutes) defaultRouteIface() string {
+	out := r.run.Run(3*time.Second, "ip", "route", "show", "default")
+	if out.Err != nil {
+		return ""
+	}
+	return devField(out.Stdout)
+}
+
+// devField returns the word following "dev" in ip-route output
+func devField(s string) string {
+	f := strings.Fields(s)
+	for i := 0; i+1 < len(f); i++ {
+		if f[i] == "dev" {
+			return f[i+1]
+		}
+	}
+	return ""
+}
+
+func (r resolvedRoutes) add(domain string, servers []string, iface string, dryRun bool) error {
+	domain = strings.TrimSuffix(strings.TrimPrefix(domain, "~"), ".")
+	if domain == "" || len(servers) == 0 {
+		return errors.New("a domain and at least one server are required")
+	}
+	defaultIface := r.defaultRouteIface()
+	if iface == "" {
+		iface = r.routeIface(servers[0])
+		if iface == "" {
+			return fmt.Errorf("could not determine the interface that reaches %s; use --interface", servers[0])
+		}
+		// the link's servers are replaced, so on the default link every
+		// query would go to the route's servers
+		if iface == defaultIface {
+			return fmt.Errorf("%s is reached over the default link %s, whose servers answer all other queries; pass --interface %s to route there anyway", servers[0], iface, iface)
+		}
+	}
+	links, err := r.links()
+	if err != nil {
+		return err
+	}
+	var link Link
+	for _, l := range links {
+		if l.Iface == iface {
+			link = l
+		}
+	}
+	domains := appendUnique(link.Domains, "~"+domain)
+
+	fmt.Printf("Routing %s to %v on interface %s\n", domain, servers, iface)
+	if len(link.Servers) > 0 && !sameServers(link.Servers, servers) {
+		fmt.Printf("Note: replacing link servers %v on %s\n", link.Servers, iface)
+	}
+	cmds := [][]string{
+		append([]string{"dns", iface}, servers...),
+		append([]string{"domain", iface}, domains...),
+	}
+	// what the link had before its first route, for remove to put back
+	records := loadRouteLinks()
+	prev, recorded := records[iface]
+	if !recorded {
+		prev = routeLink{Servers: link.Servers}
+	}
+	// a secondary link (VPN) should only answer its routing domains
+	if iface != defaultIface {
+		cmds = append(cmds, []string{"default-route", iface, "false"})
+		if !recorded && !dryRun {
+			prev.DefaultRoute = r.defaultRoute(iface)
+		}
+	}
+	if !recorded && !dryRun {
+		records[iface] = prev
+		if err := saveRouteLinks(records); err != nil {
+			return fmt.Errorf("recording the settings of %s: %w", iface, err)
+		}
+	}
+	return r.apply(cmds, dryRun)
+}
+
+func (r resolvedRoutes) remove(domain string, iface string, dryRun bool) error {
+	domain = "~" + strings.TrimSuffix(strings.TrimPrefix(domain, "~"), ".")
+	links, err := r.links()
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		if iface != "" && l.Iface != iface {
+			continue
+		}
+		var kept []string
+		found := false
+		for _, d := range l.Domains {
+			if d == domain {
+				found = true
+				continue
+			}
+			kept = append(kept, d)
+		}
+		if !found {
+			continue
+		}
+		fmt.Printf("Removing route %s from interface %s\n", strings.TrimPrefix(domain, "~"), l.Iface)
+		// without arguments resolvectl domain only prints the domains
+		if len(kept) == 0 {
+			kept = []string{""}
+		}
+		cmds := [][]string{append([]string{"domain", l.Iface}, kept...)}
+		if hasRoutingDomain(kept) {
+			return r.apply(cmds, dryRun)
+		}
+		// the last route is gone: put back the servers and default-route
+		// setting add replaced, and leave everything else on the link
+		records := loadRouteLinks()
+		prev, ok := records[l.Iface]
+		if ok {
+			servers := prev.Servers
+			if len(servers) == 0 {
+				servers = []string{""}
+			}
+			cmds = append(cmds, append([]string{"dns", l.Iface}, servers...))
+			if prev.DefaultRoute != "" {
+				cmds = append(cmds, []string{"default-route", l.Iface, prev.DefaultRoute})
+			}
+		}
+		if err := r.apply(cmds, dryRun); err != nil || dryRun || !ok {
+			return err
+		}
+		delete(records, l.Iface)
+		return saveRouteLinks(records)
+	}
+	return fmt.Errorf("no route for %s", strings.TrimPrefix(domain, "~"))
+}
+
+// defaultRoute reads the default-route setting of iface ("yes" or "no"),
+// or "" when it cannot be read
+func (r resolvedRoutes) defaultRoute(iface string) string {
+	out := r.run.Run(5*time.Second, "resolvectl", "default-route", iface)
+	if out.Err != nil {
+		return ""
+	}
+	if v := parseLinkLines(out.Stdout)[iface]; len(v) == 1 {
+		return v[0]
+	}
+	return ""
+}
+
+// routeLink is what a link had before the first route add changed it
+type routeLink struct {
+	Servers      []string `json:"servers"`
+	DefaultRoute string   `json:"default_route,omitempty"` // "yes" or "no"; empty when add left it alone
+}
+
+func routeLinksPath() string {
+	return filepath.Join(stateDir, "routes.json")
+}
+
+// loadRouteLinks reads the records by interface; a missing or unreadable
+// file is empty
+func loadRouteLinks() map[string]routeLink {
+	out := map[string]routeLink{}
+	data, err := os.ReadFile(routeLinksPath())
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return map[string]routeLink{}
+	}
+	return out
+}
+
+func saveRouteLinks(records map[string]routeLink) error {
+	if len(records) == 0 {
+		if err := os.Remove(routeLinksPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return err
+	}
+	return util.WriteAtomic(routeLinksPath(), append(data, '\n'), 0644)
+}
+
+func (r resolvedRoutes) apply(cmds [][]string, dryRun bool) error {
+	for _, args := range cmds {
+		if dryRun {
+			fmt.Printf("[DRY-RUN] Would run: resolvectl %s\n", strings.Join(quoteEmpty(args), " "))
+			continue
+		}
+		out := r.run.Run(5*time.Second, "resolvectl", args...)
+		if out.Err != nil {
+			return out.Err
+		}
+	}
+	return nil
+}
+
+// quoteEmpty shows empty arguments as "" in printed commands
+func quoteEmpty(args []string) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		if a == "" {
+			a = `""`
+		}
+		out[i] = a
+	}
+	return out
+}
+
+// hasRoutingDomain reports whether domains still route queries to the
+// link, including the catch-all "~."
+func hasRoutingDomain(domains []string) bool {
+	for _, d := range domains {
+		if strings.HasPrefix(d, "~") {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, x := range list {
+		if x == v {
+			return list
+		}
+	}
+	return append(append([]string(nil), list...), v)
+}
+
+func sameServers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,216 @@
+//go:build linux
+
+package platform
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+// fakeRunner records commands and answers from a "name args" -> result table
+type fakeRunner struct {
+	outputs map[string]util.CmdResult
+	calls   []string
+	inputs  map[string]string // stdin of RunInput calls, by command
+}
+
+func (f *fakeRunner) Run(timeout time.Duration, name string, args ...string) util.CmdResult {
+	cmd := strings.Join(append([]string{name}, args...), " ")
+	f.calls = append(f.calls, cmd)
+	res := f.outputs[cmd]
+	// failures carry the command and stderr, as from util.Run
+	var ce *util.CommandError
+	if res.Err != nil && !errors.As(res.Err, &ce) {
+		res.Err = &util.CommandError{Argv: append([]string{name}, args...), ExitCode: 1, Stderr: res.Stderr, Err: res.Err}
+	}
+	return res
+}
+
+func (f *fakeRunner) RunInput(timeout time.Duration, input string, name string, args ...string) util.CmdResult {
+	if f.inputs == nil {
+		f.inputs = map[string]string{}
+	}
+	f.inputs[strings.Join(append([]string{name}, args...), " ")] = input
+	return f.Run(timeout, name, args...)
+}
+
+func (f *fakeRunner) ran(prefix string) []string {
+	var out []string
+	for _, c := range f.calls {
+		if strings.HasPrefix(c, prefix) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+const resolvectlDNS = `Global:
+Link 2 (eth0): 192.168.1.1 fe80::1%2
+Link 5 (tun0): 10.8.0.1`
+
+const resolvectlDomain = `Global:
+Link 2 (eth0): lan
+Link 5 (tun0): ~corp.example`
+
+func newRouteRunner() *fakeRunner {
+	return &fakeRunner{outputs: map[string]util.CmdResult{
+		"resolvectl dns":                {Stdout: resolvectlDNS},
+		"resolvectl domain":             {Stdout: resolvectlDomain},
+		"resolvectl default-route tun0": {Stdout: "Link 5 (tun0): yes"},
+		"ip route show default":         {Stdout: "default via 192.168.1.1 dev eth0 proto dhcp metric 600"},
+		"ip route get 10.8.0.53":        {Stdout: "10.8.0.53 dev tun0 src 10.8.0.2 uid 1000"},
+		"ip route get 192.168.1.1":      {Stdout: "192.168.1.1 dev eth0 src 192.168.1.20 uid 1000"},
+	}}
+}
+
+// newRoutes keeps the records of route add in a temporary state directory
+func newRoutes(t *testing.T) (resolvedRoutes, *fakeRunner) {
+	orig := stateDir
+	stateDir = filepath.Join(t.TempDir(), "state")
+	t.Cleanup(func() { stateDir = orig })
+	f := newRouteRunner()
+	return resolvedRoutes{run: f}, f
+}
+
+func TestParseLinkLines(t *testing.T) {
+	links := parseLinkLines(resolvectlDNS)
+	if got := links["eth0"]; len(got) != 2 || got[1] != "fe80::1%2" {
+		t.Errorf("Expected IPv6 server to survive parsing, got %v", got)
+	}
+	if _, ok := links["Global"]; ok {
+		t.Error("Global section should not be treated as a link")
+	}
+}
+
+func TestRoutesList(t *testing.T) {
+	r := resolvedRoutes{run: newRouteRunner()}
+	list, err := r.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Domain != "corp.example" || list[0].Iface != "tun0" {
+		t.Errorf("Expected corp.example on tun0, got %+v", list)
+	}
+}
+
+func TestAddRoutePicksLinkThatReachesServer(t *testing.T) {
+	r, f := newRoutes(t)
+	if err := r.add("git.corp.example", []string{"10.8.0.53"}, "", false); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"resolvectl default-route tun0",
+		"resolvectl dns tun0 10.8.0.53",
+		"resolvectl domain tun0 ~corp.example ~git.corp.example",
+		"resolvectl default-route tun0 false",
+	}
+	got := f.ran("resolvectl ")
+	got = got[2:] // skip the dns and domain status reads
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected commands:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestAddRouteOnDefaultLinkKeepsDefaultRoute(t *testing.T) {
+	r, f := newRoutes(t)
+	if err := r.add("home.arpa", []string{"192.168.1.1"}, "eth0", false); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.ran("resolvectl default-route")) != 0 {
+		t.Error("default-route must not be changed on the default link")
+	}
+}
+
+func TestAddRouteRefusesDefaultLinkWithoutInterface(t *testing.T) {
+	r, f := newRoutes(t)
+	err := r.add("home.arpa", []string{"192.168.1.1"}, "", false)
+	if err == nil || !strings.Contains(err.Error(), "--interface eth0") {
+		t.Errorf("Expected a refusal naming --interface eth0, got %v", err)
+	}
+	if got := f.ran("resolvectl dns eth0"); len(got) != 0 {
+		t.Errorf("The default link's servers must not be replaced, ran %v", got)
+	}
+}
+
+func TestAddRouteDryRun(t *testing.T) {
+	r, f := newRoutes(t)
+	if err := r.add("corp.example", []string{"10.8.0.53"}, "tun0", true); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range f.calls {
+		if c != "resolvectl dns" && c != "resolvectl domain" && !strings.HasPrefix(c, "ip route") {
+			t.Errorf("Dry run executed %q", c)
+		}
+	}
+}
+
+func TestRemoveRouteRestoresLink(t *testing.T) {
+	r, f := newRoutes(t)
+	if err := r.add("corp.example", []string{"10.8.0.53"}, "tun0", false); err != nil {
+		t.Fatal(err)
+	}
+	f.calls = nil
+	if err := r.remove("corp.example", "", false); err != nil {
+		t.Fatal(err)
+	}
+	// an empty argument clears the domains; none would only print them,
+	// and the servers and default-route from before the add come back
+	expected := []string{
+		"resolvectl domain tun0 ",
+		"resolvectl dns tun0 10.8.0.1",
+		"resolvectl default-route tun0 yes",
+	}
+	got := f.ran("resolvectl ")[2:]
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected commands:\n%q\nexpected:\n%q", got, expected)
+	}
+	if len(f.ran("resolvectl revert")) != 0 {
+		t.Error("The link must not be reverted: that drops what the VPN client set")
+	}
+	if len(loadRouteLinks()) != 0 {
+		t.Errorf("Expected the record of tun0 to be dropped, got %v", loadRouteLinks())
+	}
+	if err := r.remove("missing.example", "", false); err == nil {
+		t.Error("Expected error when removing an unknown route")
+	}
+}
+
+func TestRemoveRouteWithoutRecordOnlyClearsDomain(t *testing.T) {
+	r, f := newRoutes(t)
+	if err := r.remove("corp.example", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.ran("resolvectl ")[2:]; len(got) != 1 || got[0] != "resolvectl domain tun0 " {
+		t.Errorf("Expected only the domain to be cleared, got %q", got)
+	}
+}
+
+func TestAddRouteIPv6Server(t *testing.T) {
+	r, f := newRoutes(t)
+	if err := r.add("corp.example", stripPorts([]string{"[fd00::53]:53"}), "tun0", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.ran("resolvectl dns tun0"); len(got) != 1 || got[0] != "resolvectl dns tun0 fd00::53" {
+		t.Errorf("Expected the whole IPv6 address, got %v", got)
+	}
+}
+
+func TestRemoveRouteKeepsLinkWithOtherRoutes(t *testing.T) {
+	f := newRouteRunner()
+	f.outputs["resolvectl domain"] = util.CmdResult{Stdout: "Link 5 (tun0): ~corp.example ~lab.example"}
+	r := resolvedRoutes{run: f}
+	if err := r.remove("corp.example", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.ran("resolvectl domain tun0"); len(got) != 1 || got[0] != "resolvectl domain tun0 ~lab.example" {
+		t.Errorf("Expected ~lab.example to be kept, got %v", got)
+	}
+	if got := f.ran("resolvectl revert"); len(got) != 0 {
+		t.Errorf("A link with routes left must not be reverted, ran %v", got)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package platform
+
+import "fmt"
+
+func AddRoute(domain string, servers []string, iface string, dryRun bool) error {
+	return fmt.Errorf("split DNS routing: %w", ErrNotSupported)
+}
+
+func RemoveRoute(domain string, iface string, dryRun bool) error {
+	return fmt.Errorf("split DNS routing: %w", ErrNotSupported)
+}
+
+func Routes() ([]Route, error) {
+	return nil, fmt.Errorf("split DNS routing: %w", ErrNotSupported)
+}
+
+func Links() ([]Link, error) {
+	return nil, fmt.Errorf("per-link DNS: %w", ErrNotSupported)
+}
@@ -0,0 +1,61 @@
+//go:build darwin || windows
+
+package platform
+
+// Record of the search domains in place before dns-helper first changed
+// them, so reset puts back only what switch replaced. Keyed by network
+// service on macOS and "global" for the machine-wide list on Windows.
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"dns-helper/internal/util"
+)
+
+// stateDir keeps dns-helper's saved state, next to the history
+var stateDir = defaultStateDir()
+
+func defaultStateDir() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "dns-helper")
+	}
+	return "/Library/Application Support/dns-helper"
+}
+
+func savedSearchPath() string {
+	return filepath.Join(stateDir, "search.json")
+}
+
+// loadSavedSearch reads the record; a missing or unreadable one is empty
+func loadSavedSearch() map[string][]string {
+	out := map[string][]string{}
+	data, err := os.ReadFile(savedSearchPath())
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return map[string][]string{}
+	}
+	return out
+}
+
+func saveSavedSearch(saved map[string][]string) error {
+	if len(saved) == 0 {
+		if err := os.Remove(savedSearchPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return err
+	}
+	return util.WriteAtomic(savedSearchPath(), append(data, '\n'), 0644)
+}
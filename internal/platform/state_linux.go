@@ -0,0 +1,69 @@
+//go:build linux
+
+package platform
+
+// Record of the changes dns-helper made, keyed by interface, local
+// resolver name or "resolv.conf", so status can tell what they survive.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"dns-helper/internal/util"
+)
+
+func appliedPath() string {
+	return filepath.Join(stateDir, "applied.json")
+}
+
+// loadApplied reads the record; a missing or unreadable one is empty
+func loadApplied() map[string]Applied {
+	out := map[string]Applied{}
+	data, err := os.ReadFile(appliedPath())
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return map[string]Applied{}
+	}
+	return out
+}
+
+// remember records a change to key, or forgets key when a is nil. The
+// change itself already succeeded, so failures are only reported.
+func remember(key string, a *Applied) {
+	if err := saveApplied(key, a); err != nil {
+		fmt.Printf("Note: could not record the change in %s: %v\n", appliedPath(), err)
+	}
+}
+
+func saveApplied(key string, a *Applied) error {
+	state := loadApplied()
+	if a == nil {
+		if _, ok := state[key]; !ok {
+			return nil
+		}
+		delete(state, key)
+	} else {
+		a.Time = time.Now().UTC()
+		state[key] = *a
+	}
+	if len(state) == 0 {
+		if err := os.Remove(appliedPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return err
+	}
+	return util.WriteAtomic(appliedPath(), append(data, '\n'), 0644)
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRememberAndForget(t *testing.T) {
+	orig := stateDir
+	stateDir = filepath.Join(t.TempDir(), "state")
+	t.Cleanup(func() { stateDir = orig })
+
+	remember("eth0", &Applied{Backend: "systemd-networkd", Durability: persistent, Servers: []string{"1.1.1.1"}})
+	remember("wlp3s0", &Applied{Backend: "systemd-resolved", Durability: runtimeOnly, Servers: []string{"9.9.9.9"}})
+	state := loadApplied()
+	if a := state["eth0"]; a.Backend != "systemd-networkd" || !a.Durability.Reboot || a.Time.IsZero() {
+		t.Errorf("Unexpected record for eth0: %+v", a)
+	}
+	if a := state["wlp3s0"]; a.Durability != runtimeOnly {
+		t.Errorf("Unexpected record for wlp3s0: %+v", a)
+	}
+
+	remember("eth0", nil)
+	remember("wlp3s0", nil)
+	if len(loadApplied()) != 0 {
+		t.Errorf("Records left: %v", loadApplied())
+	}
+	if _, err := os.Stat(appliedPath()); !os.IsNotExist(err) {
+		t.Error("An empty record must be removed")
+	}
+}
+
+func TestCandidatesNoneInUse(t *testing.T) {
+	orig := modeBackends
+	t.Cleanup(func() { modeBackends = orig })
+	modeBackends = []backend{
+		netplanBackend{run: &fakeRunner{}},
+		resolvconfBackend{run: &fakeRunner{}},
+	}
+	origDir := netplanDir
+	netplanDir = t.TempDir()
+	t.Cleanup(func() { netplanDir = origDir })
+	_, err := candidates(ModePersist, "")
+	if err == nil || !strings.Contains(err.Error(), "--persist needs one of netplan") || strings.Contains(err.Error(), "resolvconf") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,332 @@
+package report
+
+// Self-contained HTML benchmark report: inline CSS and SVG charts, and the
+// raw samples embedded as JSON, so the file can be mailed or attached to a
+// review without anything else.
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"dns-helper/internal/bench"
+)
+
+// Meta describes the run
+type Meta struct {
+	Time     time.Time     `json:"time"`
+	Host     string        `json:"host"`
+	Platform string        `json:"platform"` // GOOS/GOARCH
+	Network  []string      `json:"network"`  // "iface: servers" as configured during the run
+	Version  string        `json:"version"`
+	Profiles []string      `json:"profiles"`
+	Domains  []string      `json:"domains"`
+	Runs     int           `json:"runs"`
+	Timeout  time.Duration `json:"timeout_ns"`
+}
+
+// palette colors profiles in name order
+var palette = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// failureClasses are the columns of the failure breakdown
+var failureClasses = []string{bench.ClassNXDomain, bench.ClassServFail, bench.ClassRefused, bench.ClassRCode, bench.ClassTimeout, bench.ClassNetwork, bench.ClassError}
+
+type summaryRow struct {
+	Profile, Color     string
+	Avg, P50, P90      float64
+	Successes, Total   int
+	Failures           []int // by failureClasses
+	SuccessRatePercent float64
+}
+
+type page struct {
+	Meta    Meta
+	Timeout string
+	Summary []summaryRow
+	Classes []string
+	CDF     template.HTML
+	Boxes   []domainBoxes
+	Data    template.JS
+}
+
+type domainBoxes struct {
+	Domain string
+	Chart  template.HTML
+}
+
+// Write renders the report for results, keyed by profile
+func Write(w io.Writer, meta Meta, results map[string]bench.Result) error {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	colors := map[string]string{}
+	for i, name := range names {
+		colors[name] = palette[i%len(palette)]
+	}
+
+	p := page{Meta: meta, Timeout: meta.Timeout.String(), Classes: failureClasses}
+	var samples []bench.Sample
+	cdf := map[string][]float64{}
+	for _, name := range names {
+		r := results[name]
+		row := summaryRow{Profile: name, Color: colors[name], Avg: r.AvgMS(), P50: r.P50MS(), P90: r.P90MS(),
+			Successes: r.Successes, Total: r.Total, Failures: make([]int, len(failureClasses))}
+		if r.Total > 0 {
+			row.SuccessRatePercent = 100 * float64(r.Successes) / float64(r.Total)
+		}
+		for _, s := range r.Samples {
+			for i, c := range failureClasses {
+				if s.Class == c {
+					row.Failures[i]++
+				}
+			}
+		}
+		p.Summary = append(p.Summary, row)
+		for _, d := range r.Latencies {
+			cdf[name] = append(cdf[name], ms(d))
+		}
+		samples = append(samples, r.Samples...)
+	}
+	p.CDF = cdfChart(names, colors, cdf)
+
+	for _, domain := range meta.Domains {
+		values := map[string][]float64{}
+		for _, name := range names {
+			for _, s := range results[name].Samples {
+				if s.Domain == domain && s.Class == bench.ClassSuccess {
+					values[name] = append(values[name], ms(s.Latency))
+				}
+			}
+		}
+		p.Boxes = append(p.Boxes, domainBoxes{Domain: domain, Chart: boxChart(names, colors, values)})
+	}
+
+	data, err := json.Marshal(struct {
+		Meta    Meta           `json:"meta"`
+		Samples []bench.Sample `json:"samples"`
+	}{meta, samples})
+	if err != nil {
+		return err
+	}
+	// json.Marshal escapes <, > and &, so the data cannot close the script
+	p.Data = template.JS(data)
+	return tmpl.Execute(w, p)
+}
+
+func ms(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// chart geometry, in SVG user units
+const (
+	chartW, chartH = 720, 300
+	padL, padR     = 56, 150 // the legend sits in the right padding
+	padT, padB     = 16, 40
+)
+
+// cdfChart draws one step line per profile: the share of successful
+// queries answered within x milliseconds
+func cdfChart(names []string, colors map[string]string, values map[string][]float64) template.HTML {
+	max := 0.0
+	for _, v := range values {
+		for _, x := range v {
+			max = math.Max(max, x)
+		}
+	}
+	if max == 0 {
+		return template.HTML(`<p class="empty">No successful queries.</p>`)
+	}
+	xmax := niceCeil(max)
+	plotW, plotH := float64(chartW-padL-padR), float64(chartH-padT-padB)
+	x := func(v float64) float64 { return padL + v/xmax*plotW }
+	y := func(v float64) float64 { return padT + (1-v)*plotH }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %d %d" role="img" aria-label="Latency CDF">`, chartW, chartH)
+	axes(&b, xmax, "latency (ms)", func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) }, x, y, 1)
+	for i, name := range names {
+		v := append([]float64(nil), values[name]...)
+		if len(v) == 0 {
+			continue
+		}
+		sort.Float64s(v)
+		pts := []string{fmt.Sprintf("%.1f,%.1f", x(0), y(0))}
+		for j, val := range v {
+			prev := float64(j) / float64(len(v))
+			next := float64(j+1) / float64(len(v))
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f %.1f,%.1f", x(val), y(prev), x(val), y(next)))
+		}
+		pts = append(pts, fmt.Sprintf("%.1f,%.1f", x(xmax), y(1)))
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"><title>%s</title></polyline>`,
+			colors[name], strings.Join(pts, " "), template.HTMLEscapeString(name))
+		legend(&b, i, name, colors[name])
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// boxChart draws one box per profile: whiskers at the minimum and
+// maximum, the box from the first to the third quartile, and the median
+func boxChart(names []string, colors map[string]string, values map[string][]float64) template.HTML {
+	max := 0.0
+	for _, v := range values {
+		for _, x := range v {
+			max = math.Max(max, x)
+		}
+	}
+	if max == 0 {
+		return template.HTML(`<p class="empty">No successful queries.</p>`)
+	}
+	ymax := niceCeil(max)
+	plotW, plotH := float64(chartW-padL-padR), float64(chartH-padT-padB)
+	y := func(v float64) float64 { return padT + (1-v/ymax)*plotH }
+	slot := plotW / float64(len(names))
+	boxW := math.Min(slot*0.5, 60)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %d %d" role="img" aria-label="Latency by profile">`, chartW, chartH)
+	axes(&b, 0, "", func(f float64) string { return fmt.Sprintf("%.0f ms", f) }, nil, y, ymax)
+	for i, name := range names {
+		cx := padL + slot*(float64(i)+0.5)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, cx, chartH-padB+16, template.HTMLEscapeString(name))
+		v := append([]float64(nil), values[name]...)
+		if len(v) == 0 {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle" class="empty">no data</text>`, cx, y(ymax/2))
+			continue
+		}
+		sort.Float64s(v)
+		lo, q1, med, q3, hi := v[0], quantile(v, 0.25), quantile(v, 0.5), quantile(v, 0.75), v[len(v)-1]
+		c := colors[name]
+		fmt.Fprintf(&b, `<g><title>%s: min %.1f, q1 %.1f, median %.1f, q3 %.1f, max %.1f ms (n=%d)</title>`,
+			template.HTMLEscapeString(name), lo, q1, med, q3, hi, len(v))
+		fmt.Fprintf(&b, `<line x1="%.1f" x2="%.1f" y1="%.1f" y2="%.1f" stroke="%s"/>`, cx, cx, y(hi), y(q3), c)
+		fmt.Fprintf(&b, `<line x1="%.1f" x2="%.1f" y1="%.1f" y2="%.1f" stroke="%s"/>`, cx, cx, y(q1), y(lo), c)
+		for _, w := range []float64{lo, hi} {
+			fmt.Fprintf(&b, `<line x1="%.1f" x2="%.1f" y1="%.1f" y2="%.1f" stroke="%s"/>`, cx-boxW/4, cx+boxW/4, y(w), y(w), c)
+		}
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" fill-opacity="0.25" stroke="%s"/>`,
+			cx-boxW/2, y(q3), boxW, math.Max(y(q1)-y(q3), 1), c, c)
+		fmt.Fprintf(&b, `<line x1="%.1f" x2="%.1f" y1="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/></g>`,
+			cx-boxW/2, cx+boxW/2, y(med), y(med), c)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// axes draws the frame with five ticks on the y axis and, when x is set,
+// on the x axis; y tick values run from 0 to ytop
+func axes(b *strings.Builder, xmax float64, xlabel string, ytick func(float64) string, x, y func(float64) float64, ytop float64) {
+	fmt.Fprintf(b, `<g class="axis"><line x1="%d" x2="%d" y1="%d" y2="%d"/><line x1="%d" x2="%d" y1="%d" y2="%d"/>`,
+		padL, padL, padT, chartH-padB, padL, chartW-padR, chartH-padB, chartH-padB)
+	for i := 0; i <= 4; i++ {
+		v := ytop * float64(i) / 4
+		fmt.Fprintf(b, `<line class="grid" x1="%d" x2="%d" y1="%.1f" y2="%.1f"/><text x="%d" y="%.1f" text-anchor="end">%s</text>`,
+			padL, chartW-padR, y(v), y(v), padL-6, y(v)+4, ytick(v))
+		if x != nil {
+			xv := xmax * float64(i) / 4
+			fmt.Fprintf(b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x(xv), chartH-padB+16, trimFloat(xv))
+		}
+	}
+	if xlabel != "" {
+		fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="middle">%s</text>`, padL+(chartW-padL-padR)/2, chartH-6, xlabel)
+	}
+	b.WriteString(`</g>`)
+}
+
+func legend(b *strings.Builder, i int, name, color string) {
+	ly := padT + 10 + i*18
+	fmt.Fprintf(b, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/><text x="%d" y="%d">%s</text>`,
+		chartW-padR+12, ly-10, color, chartW-padR+30, ly, template.HTMLEscapeString(name))
+}
+
+// quantile interpolates linearly between the closest ranks of sorted v
+func quantile(v []float64, q float64) float64 {
+	pos := q * float64(len(v)-1)
+	i := int(pos)
+	if i+1 >= len(v) {
+		return v[len(v)-1]
+	}
+	return v[i] + (v[i+1]-v[i])*(pos-float64(i))
+}
+
+// niceCeil rounds v up to 1, 2 or 5 times a power of ten
+func niceCeil(v float64) float64 {
+	p := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*p >= v {
+			return m * p
+		}
+	}
+	return 10 * p
+}
+
+func trimFloat(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
+
+var tmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"join": strings.Join,
+	"utc":  func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>DNS benchmark {{.Meta.Host}} {{utc .Meta.Time}}</title>
+<style>
+body { font: 14px/1.4 system-ui, sans-serif; margin: 2em auto; max-width: 960px; color: #222; }
+h1 { font-size: 1.5em; } h2 { font-size: 1.2em; margin-top: 2em; } h3 { font-size: 1em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+dl { display: grid; grid-template-columns: max-content auto; gap: 2px 1em; }
+dt { font-weight: 600; } dd { margin: 0; }
+svg { width: 100%; height: auto; font-size: 11px; }
+.axis line { stroke: #444; } .grid { stroke: #e5e5e5; }
+.swatch { display: inline-block; width: 10px; height: 10px; margin-right: 6px; }
+.empty { color: #888; }
+</style>
+</head>
+<body>
+<h1>DNS benchmark</h1>
+<dl>
+<dt>Time</dt><dd>{{utc .Meta.Time}}</dd>
+<dt>Host</dt><dd>{{.Meta.Host}} ({{.Meta.Platform}})</dd>
+<dt>Network</dt><dd>{{range .Meta.Network}}{{.}}<br>{{else}}unknown{{end}}</dd>
+<dt>Profiles</dt><dd>{{join .Meta.Profiles ", "}}</dd>
+<dt>Domains</dt><dd>{{join .Meta.Domains ", "}}</dd>
+<dt>Parameters</dt><dd>{{.Meta.Runs}} runs per domain, timeout {{.Timeout}}</dd>
+<dt>dns-helper</dt><dd>{{.Meta.Version}}</dd>
+</dl>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Profile</th><th>avg (ms)</th><th>p50 (ms)</th><th>p90 (ms)</th><th>success</th></tr>
+{{range .Summary}}<tr><td><span class="swatch" style="background: {{.Color}}"></span>{{.Profile}}</td><td>{{printf "%.1f" .Avg}}</td><td>{{printf "%.1f" .P50}}</td><td>{{printf "%.1f" .P90}}</td><td>{{.Successes}}/{{.Total}} ({{printf "%.1f" .SuccessRatePercent}}%)</td></tr>
+{{end}}</table>
+
+<h2>Latency distribution</h2>
+<p>Share of successful queries answered within a given time.</p>
+{{.CDF}}
+
+<h2>Latency by domain</h2>
+<p>Boxes span the first to third quartile with the median marked; whiskers reach the fastest and slowest successful query.</p>
+{{range .Boxes}}<h3>{{.Domain}}</h3>
+{{.Chart}}
+{{end}}
+<h2>Failures</h2>
+<table>
+<tr><th>Profile</th>{{range .Classes}}<th>{{.}}</th>{{end}}</tr>
+{{range .Summary}}<tr><td>{{.Profile}}</td>{{range .Failures}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+
+<h2>Raw data</h2>
+<p>Every query is embedded below as JSON (<code>meta</code> and <code>samples</code>; latencies in nanoseconds).</p>
+<script type="application/json" id="dns-helper-data">{{.Data}}</script>
+</body>
+</html>
+`))
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-helper/internal/bench"
+)
+
+func testResults() map[string]bench.Result {
+	results := map[string]bench.Result{}
+	for name, base := range map[string]time.Duration{"cloudflare": 10 * time.Millisecond, "quad9": 30 * time.Millisecond} {
+		var r bench.Result
+		for _, domain := range []string{"example.com", "example.org"} {
+			for i := 0; i < 4; i++ {
+				lat := base + time.Duration(i)*time.Millisecond
+				r.Total++
+				r.Successes++
+				r.Latencies = append(r.Latencies, lat)
+				r.Samples = append(r.Samples, bench.Sample{Profile: name, Domain: domain, Class: bench.ClassSuccess, Latency: lat})
+			}
+		}
+		results[name] = r
+	}
+	q := results["quad9"]
+	q.Total++
+	q.Samples = append(q.Samples, bench.Sample{Profile: "quad9", Domain: "example.org", Class: bench.ClassTimeout})
+	results["quad9"] = q
+	return results
+}
+
+func TestWriteReport(t *testing.T) {
+	meta := Meta{Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Host: "lab<1>", Domains: []string{"example.com", "example.org"},
+		Profiles: []string{"cloudflare", "quad9"}, Runs: 4, Timeout: time.Second}
+	var b strings.Builder
+	if err := Write(&b, meta, testResults()); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	if strings.Contains(out, "ZgotmplZ") || strings.Contains(out, "lab<1>") {
+		t.Error("Values must be escaped, not filtered or inserted raw")
+	}
+	if n := strings.Count(out, "<polyline"); n != 2 {
+		t.Errorf("Expected one CDF line per profile, got %d", n)
+	}
+	if n := strings.Count(out, "<rect x=") - 2; n != 2*2 {
+		t.Errorf("Expected a legend swatch per profile and a box per profile and domain, got %d boxes", n)
+	}
+	if !strings.Contains(out, "<tr><td>quad9</td><td>0</td><td>0</td><td>0</td><td>0</td><td>1</td>") {
+		t.Error("Expected the timeout in the failure breakdown")
+	}
+	for _, ref := range []string{"http://", "https://", "<link", "src="} {
+		if strings.Contains(out, ref) {
+			t.Errorf("The report must not load external assets, found %q", ref)
+		}
+	}
+
+	start := strings.Index(out, `id="dns-helper-data">`) + len(`id="dns-helper-data">`)
+	end := strings.Index(out[start:], "</script>")
+	var data struct {
+		Meta    Meta           `json:"meta"`
+		Samples []bench.Sample `json:"samples"`
+	}
+	if err := json.Unmarshal([]byte(out[start:start+end]), &data); err != nil {
+		t.Fatalf("Embedded data is not JSON: %v", err)
+	}
+	if len(data.Samples) != 17 || data.Meta.Host != "lab<1>" {
+		t.Errorf("Unexpected embedded data: %d samples, host %q", len(data.Samples), data.Meta.Host)
+	}
+}
+
+func TestQuantileAndNiceCeil(t *testing.T) {
+	v := []float64{1, 2, 3, 4}
+	if q := quantile(v, 0.5); q != 2.5 {
+		t.Errorf("median of %v = %v", v, q)
+	}
+	for in, expected := range map[float64]float64{0.7: 1, 13: 20, 42: 50, 100: 100, 101: 200} {
+		if got := niceCeil(in); got != expected {
+			t.Errorf("niceCeil(%v) = %v, expected %v", in, got, expected)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package resolvconf
+
+// Parser and writer for resolv.conf(5). Only the directives dns-helper
+// edits are modelled; every other line, including comments, is kept
+// verbatim so a rewrite changes nothing but the nameservers.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"dns-helper/internal/util"
+)
+
+// DefaultPath is the system resolver configuration
+const DefaultPath = "/etc/resolv.conf"
+
+// ErrManaged is returned when the file belongs to another service
+var ErrManaged = errors.New("resolv.conf is managed by another service")
+
+// Header marks files written by dns-helper
+const Header = "# Generated by dns-helper"
+
+// Line is one line of the file
+type Line struct {
+	Directive string   // "nameserver", "search", "domain", "options", ... or "" for comments and blanks
+	Values    []string // directive arguments
+	Raw       string   // original text, used when the line is not modified
+}
+
+// File is a parsed resolv.conf
+type File struct {
+	Lines []Line
+}
+
+// Parse reads resolv.conf content
+func Parse(data []byte) *File {
+	f := &File{}
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return f
+	}
+	for _, raw := range strings.Split(text, "\n") {
+		l := Line{Raw: raw}
+		trimmed := strings.TrimSpace(raw)
+		if trimmed != "" && trimmed[0] != '#' && trimmed[0] != ';' {
+			fields := strings.Fields(trimmed)
+			l.Directive, l.Values = fields[0], fields[1:]
+		}
+		f.Lines = append(f.Lines, l)
+	}
+	return f
+}
+
+// Read parses the file at path
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data), nil
+}
+
+func (f *File) values(directive string) []string {
+	var out []string
+	for _, l := range f.Lines {
+		if l.Directive == directive {
+			out = append(out, l.Values...)
+		}
+	}
+	return out
+}
+
+// Nameservers returns the configured servers in order
+func (f *File) Nameservers() []string {
+	var out []string
+	for _, l := range f.Lines {
+		if l.Directive == "nameserver" && len(l.Values) > 0 {
+			out = append(out, l.Values[0])
+		}
+	}
+	return out
+}
+
+// Search returns the search list. As in the resolver, the last "search"
+// or "domain" line wins.
+func (f *File) Search() []string {
+	var out []string
+	for _, l := range f.Lines {
+		if l.Directive == "search" || l.Directive == "domain" {
+			out = l.Values
+		}
+	}
+	return out
+}
+
+// Options returns the resolver options ("ndots:2", "rotate", ...)
+func (f *File) Options() []string {
+	return f.values("options")
+}
+
+// Comments returns the comment lines, without their leading marker
+func (f *File) Comments() []string {
+	var out []string
+	for _, l := range f.Lines {
+		t := strings.TrimSpace(l.Raw)
+		if l.Directive == "" && t != "" {
+			out = append(out, strings.TrimSpace(t[1:]))
+		}
+	}
+	return out
+}
+
+// SetNameservers replaces the nameserver lines
+func (f *File) SetNameservers(servers []string) {
+	f.replace(func(d string) bool { return d == "nameserver" }, lines("nameserver", servers, true))
+}
+
+// SetSearch replaces the search list; "domain" lines are dropped since
+// the last of search and domain wins anyway
+func (f *File) SetSearch(domains []string) {
+	f.replace(func(d string) bool { return d == "search" || d == "domain" }, lines("search", domains, false))
+}
+
+// SetOptions sets resolver options. An option replaces an existing one
+// with the same name ("ndots:5" replaces "ndots:1"); others are kept.
+func (f *File) SetOptions(opts []string) {
+	name := func(o string) string { return strings.SplitN(o, ":", 2)[0] }
+	set := map[string]bool{}
+	for _, o := range opts {
+		set[name(o)] = true
+	}
+	var merged []string
+	for _, o := range f.Options() {
+		if !set[name(o)] {
+			merged = append(merged, o)
+		}
+	}
+	merged = append(merged, opts...)
+	f.replace(func(d string) bool { return d == "options" }, lines("options", merged, false))
+}
+
+// lines builds directive lines, one per value or a single line for all
+func lines(directive string, values []string, each bool) []Line {
+	if len(values) == 0 {
+		return nil
+	}
+	if !each {
+		return []Line{{Directive: directive, Values: values, Raw: directive + " " + strings.Join(values, " ")}}
+	}
+	out := make([]Line, 0, len(values))
+	for _, v := range values {
+		out = append(out, Line{Directive: directive, Values: []string{v}, Raw: directive + " " + v})
+	}
+	return out
+}
+
+// replace drops the lines matching the directive test and puts repl in
+// place of the first of them, or at the end when there was none
+func (f *File) replace(match func(string) bool, repl []Line) {
+	var out []Line
+	inserted := false
+	for _, l := range f.Lines {
+		if l.Directive != "" && match(l.Directive) {
+			if !inserted {
+				out = append(out, repl...)
+				inserted = true
+			}
+			continue
+		}
+		out = append(out, l)
+	}
+	if !inserted {
+		out = append(out, repl...)
+	}
+	f.Lines = out
+}
+
+// resolver options understood by glibc, with whether they take a value
+var knownOptions = map[string]bool{
+	"ndots": true, "timeout": true, "attempts": true,
+	"rotate": false, "edns0": false, "trust-ad": false, "inet6": false,
+	"single-request": false, "single-request-reopen": false, "no-tld-query": false,
+	"use-vc": false, "no-reload": false, "no-aaaa": false, "debug": false,
+}
+
+// ValidOption checks an option such as "ndots:5" or "rotate"
+func ValidOption(o string) error {
+	name, val, hasVal := strings.Cut(o, ":")
+	takesVal, ok := knownOptions[name]
+	switch {
+	case !ok:
+		return fmt.Errorf("unknown resolver option: %s", name)
+	case takesVal && !hasVal:
+		return fmt.Errorf("resolver option %s needs a value (e.g. %s:2)", name, name)
+	case !takesVal && hasVal:
+		return fmt.Errorf("resolver option %s takes no value", name)
+	case takesVal:
+		if _, err := strconv.ParseUint(val, 10, 8); err != nil {
+			return fmt.Errorf("invalid value for %s: %s", name, val)
+		}
+	}
+	return nil
+}
+
+// Bytes renders the file
+func (f *File) Bytes() []byte {
+	var b bytes.Buffer
+	for _, l := range f.Lines {
+		b.WriteString(l.Raw)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// Info describes who owns a resolv.conf
+type Info struct {
+	Symlink   bool
+	Target    string // symlink target, resolved
+	ManagedBy string // "systemd-resolved", "NetworkManager", "resolvconf", "dns-helper" or ""
+}
+
+// Managed reports whether a service other than dns-helper owns the file
+func (i Info) Managed() bool {
+	return i.ManagedBy != "" && i.ManagedBy != "dns-helper"
+}
+
+// Symlink target prefixes and header comments that identify the owner
+var (
+	managedTargets = map[string]string{
+		"/run/systemd/resolve/": "systemd-resolved",
+		"/usr/lib/systemd/":     "systemd-resolved",
+		"/run/NetworkManager/":  "NetworkManager",
+		"/run/resolvconf/":      "resolvconf",
+		"/etc/resolvconf/run/":  "resolvconf",
+		"/run/connman/":         "connman",
+		"/run/netconfig/":       "netconfig",
+	}
+	managedHeaders = map[string]string{
+		"managed by man:systemd-resolved": "systemd-resolved",
+		"generated by networkmanager":     "NetworkManager",
+		"generated by resolvconf":         "resolvconf",
+		"generated by openresolv":         "resolvconf",
+		"generated by connection manager": "connman",
+		"generated by dhcpcd":             "dhcpcd",
+		"generated by dns-helper":         "dns-helper",
+	}
+)
+
+// Inspect reports whether path is a symlink and which service owns it
+func Inspect(path string) (Info, error) {
+	var info Info
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return info, err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		info.Symlink = true
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return info, fmt.Errorf("dangling symlink %s: %v", path, err)
+		}
+		info.Target = target
+		for prefix, owner := range managedTargets {
+			if strings.HasPrefix(target, prefix) {
+				info.ManagedBy = owner
+				return info, nil
+			}
+		}
+	}
+	f, err := Read(path)
+	if err != nil {
+		return info, err
+	}
+	for _, c := range f.Comments() {
+		c = strings.ToLower(c)
+		for marker, owner := range managedHeaders {
+			if strings.Contains(c, marker) {
+				info.ManagedBy = owner
+				return info, nil
+			}
+		}
+	}
+	return info, nil
+}
+
+// Update applies edit to the file at path and writes it back, changing
+// nothing the edit does not touch. It refuses with ErrManaged when another
+// service owns the file, unless force is set. The previous content is kept
+// at BackupPath(path) the first time dns-helper takes over the file, and a
+// replaced symlink's target at LinkBackupPath(path).
+func Update(path string, edit func(*File), force, dryRun bool) error {
+	info, err := Inspect(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if info.Managed() && !force {
+		return fmt.Errorf("%w: %s (use --force to overwrite)", ErrManaged, describe(path, info))
+	}
+	f := &File{}
+	if err == nil {
+		if f, err = Read(path); err != nil {
+			return err
+		}
+	}
+	old := f.Bytes()
+	edit(f)
+	if info.ManagedBy != "dns-helper" {
+		f.Lines = append([]Line{{Raw: Header}}, f.Lines...)
+	}
+	if dryRun {
+		fmt.Printf("[DRY-RUN] Would write %s:\n%s", path, f.Bytes())
+		return nil
+	}
+	if info.Managed() {
+		fmt.Printf("Warning: replacing %s; %s will no longer update it\n", describe(path, info), info.ManagedBy)
+	}
+	if info.ManagedBy != "dns-helper" && len(old) > 0 {
+		if err := os.WriteFile(BackupPath(path), old, 0644); err != nil {
+			return fmt.Errorf("saving backup: %v", err)
+		}
+	}
+	if info.Symlink {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(LinkBackupPath(path), []byte(target+"\n"), 0644); err != nil {
+			return fmt.Errorf("saving symlink target: %v", err)
+		}
+	}
+	return util.WriteAtomic(path, f.Bytes(), 0644)
+}
+
+// Restore puts back the symlink or the content saved by Update. Without
+// a backup the nameservers are removed and every other line is kept.
+func Restore(path string, dryRun bool) error {
+	backup := BackupPath(path)
+	if data, err := os.ReadFile(LinkBackupPath(path)); err == nil {
+		target := strings.TrimSpace(string(data))
+		if dryRun {
+			fmt.Printf("[DRY-RUN] Would restore the symlink %s -> %s\n", path, target)
+			return nil
+		}
+		if err := symlinkAtomic(target, path); err != nil {
+			return err
+		}
+		if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return os.Remove(LinkBackupPath(path))
+	}
+	if data, err := os.ReadFile(backup); err == nil {
+		if dryRun {
+			fmt.Printf("[DRY-RUN] Would restore %s from %s\n", path, backup)
+			return nil
+		}
+		if err := util.WriteAtomic(path, data, 0644); err != nil {
+			return err
+		}
+		return os.Remove(backup)
+	}
+	info, err := Inspect(path)
+	if err != nil {
+		return err
+	}
+	if info.Managed() {
+		return fmt.Errorf("%w: %s", ErrManaged, describe(path, info))
+	}
+	f, err := Read(path)
+	if err != nil {
+		return err
+	}
+	f.SetNameservers(nil)
+	if dryRun {
+		fmt.Printf("[DRY-RUN] Would write %s:\n%s", path, f.Bytes())
+		return nil
+	}
+	return util.WriteAtomic(path, f.Bytes(), 0644)
+}
+
+// BackupPath is where Update keeps the original file
+func BackupPath(path string) string {
+	return path + ".dns-helper.bak"
+}
+
+// LinkBackupPath is where Update keeps the target of a replaced symlink
+func LinkBackupPath(path string) string {
+	return path + ".dns-helper.link"
+}
+
+// symlinkAtomic points path at target, replacing the file in one rename
+func symlinkAtomic(target, path string) error {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".dns-helper-link")
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func describe(path string, info Info) string {
+	if info.Symlink {
+		return fmt.Sprintf("%s -> %s (%s)", path, info.Target, info.ManagedBy)
+	}
+	return fmt.Sprintf("%s (%s)", path, info.ManagedBy)
+}
@@ -0,0 +1,250 @@
+package resolvconf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sample = `# corporate resolver settings
+search corp.example.com example.com
+nameserver 10.0.0.2
+; secondary
+nameserver 10.0.0.3
+options ndots:2 timeout:1 rotate
+options edns0
+`
+
+const stub = `# This file is managed by man:systemd-resolved(8). Do not edit.
+nameserver 127.0.0.53
+options edns0 trust-ad
+`
+
+func writeFile(t *testing.T, content string) string {
+	p := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func servers(s ...string) func(*File) {
+	return func(f *File) { f.SetNameservers(s) }
+}
+
+func TestParse(t *testing.T) {
+	f := Parse([]byte(sample))
+	if got := f.Nameservers(); strings.Join(got, ",") != "10.0.0.2,10.0.0.3" {
+		t.Errorf("Nameservers() = %v", got)
+	}
+	if got := f.Search(); strings.Join(got, ",") != "corp.example.com,example.com" {
+		t.Errorf("Search() = %v", got)
+	}
+	if got := f.Options(); strings.Join(got, ",") != "ndots:2,timeout:1,rotate,edns0" {
+		t.Errorf("Options() = %v", got)
+	}
+	if got := f.Comments(); len(got) != 2 || got[1] != "secondary" {
+		t.Errorf("Comments() = %v", got)
+	}
+	if string(f.Bytes()) != sample {
+		t.Errorf("round trip changed the file:\n%s", f.Bytes())
+	}
+}
+
+func TestSearchLastWins(t *testing.T) {
+	f := Parse([]byte("search a.example\ndomain b.example\n"))
+	if got := f.Search(); len(got) != 1 || got[0] != "b.example" {
+		t.Errorf("Expected [b.example], got %v", got)
+	}
+}
+
+func TestSetNameserversKeepsOtherLines(t *testing.T) {
+	f := Parse([]byte(sample))
+	f.SetNameservers([]string{"1.1.1.1", "1.0.0.1"})
+	want := `# corporate resolver settings
+search corp.example.com example.com
+nameserver 1.1.1.1
+nameserver 1.0.0.1
+; secondary
+options ndots:2 timeout:1 rotate
+options edns0
+`
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	f = Parse([]byte("options rotate\n"))
+	f.SetNameservers([]string{"9.9.9.9"})
+	if got := string(f.Bytes()); got != "options rotate\nnameserver 9.9.9.9\n" {
+		t.Errorf("Expected appended nameserver, got:\n%s", got)
+	}
+}
+
+func TestSetSearchAndOptions(t *testing.T) {
+	f := Parse([]byte(sample + "domain old.example\n"))
+	f.SetSearch([]string{"svc.cluster.local", "cluster.local"})
+	f.SetOptions([]string{"ndots:5", "attempts:3"})
+	want := `# corporate resolver settings
+search svc.cluster.local cluster.local
+nameserver 10.0.0.2
+; secondary
+nameserver 10.0.0.3
+options timeout:1 rotate edns0 ndots:5 attempts:3
+`
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestValidOption(t *testing.T) {
+	for _, o := range []string{"ndots:5", "timeout:2", "attempts:3", "rotate", "edns0"} {
+		if err := ValidOption(o); err != nil {
+			t.Errorf("ValidOption(%q) = %v", o, err)
+		}
+	}
+	for _, o := range []string{"ndots", "ndots:x", "rotate:1", "bogus"} {
+		if err := ValidOption(o); err == nil {
+			t.Errorf("Expected ValidOption(%q) to fail", o)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	p := writeFile(t, sample)
+	info, err := Inspect(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Symlink || info.Managed() {
+		t.Errorf("Expected an unmanaged regular file, got %+v", info)
+	}
+
+	target := writeFile(t, stub)
+	link := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	info, err = Inspect(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Symlink || info.Target != target || info.ManagedBy != "systemd-resolved" {
+		t.Errorf("Expected a systemd-resolved symlink, got %+v", info)
+	}
+}
+
+func TestInspectResolvconfHeader(t *testing.T) {
+	p := writeFile(t, "# Dynamic resolv.conf(5) file for glibc resolver(3) generated by resolvconf(8)\nnameserver 192.168.1.1\n")
+	info, err := Inspect(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ManagedBy != "resolvconf" {
+		t.Errorf("Expected resolvconf, got %+v", info)
+	}
+}
+
+func TestUpdateRefusesManaged(t *testing.T) {
+	p := writeFile(t, stub)
+	err := Update(p, servers("1.1.1.1"), false, false)
+	if !errors.Is(err, ErrManaged) {
+		t.Fatalf("Expected ErrManaged, got %v", err)
+	}
+	data, _ := os.ReadFile(p)
+	if string(data) != stub {
+		t.Errorf("managed file was modified:\n%s", data)
+	}
+}
+
+func TestUpdateReplacesSymlink(t *testing.T) {
+	target := writeFile(t, stub)
+	link := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := Update(link, servers("1.1.1.1"), true, false); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("Expected the symlink to be replaced by a regular file")
+	}
+	if data, _ := os.ReadFile(target); string(data) != stub {
+		t.Errorf("wrote through the symlink:\n%s", data)
+	}
+}
+
+func TestRestoreRecreatesSymlink(t *testing.T) {
+	target := writeFile(t, stub)
+	link := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := Update(link, servers("1.1.1.1"), true, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := Update(link, servers("8.8.8.8"), false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(link, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.Readlink(link); err != nil || got != target {
+		t.Errorf("Expected the symlink to %s to be back, got %q, %v", target, got, err)
+	}
+	for _, p := range []string{BackupPath(link), LinkBackupPath(link)} {
+		if _, err := os.Lstat(p); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", p)
+		}
+	}
+	info, err := Inspect(link)
+	if err != nil || info.ManagedBy != "systemd-resolved" {
+		t.Errorf("Expected the file to belong to systemd-resolved again, got %+v, %v", info, err)
+	}
+}
+
+func TestUpdateAndRestore(t *testing.T) {
+	p := writeFile(t, sample)
+	if err := Update(p, servers("1.1.1.1"), false, false); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Read(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Nameservers(); len(got) != 1 || got[0] != "1.1.1.1" {
+		t.Errorf("Nameservers() = %v", got)
+	}
+	if strings.Join(f.Options(), " ") != "ndots:2 timeout:1 rotate edns0" {
+		t.Errorf("options were lost: %v", f.Options())
+	}
+	info, _ := Inspect(p)
+	if info.ManagedBy != "dns-helper" {
+		t.Errorf("Expected the dns-helper header, got %+v", info)
+	}
+
+	// a second switch must not overwrite the original backup
+	if err := Update(p, servers("8.8.8.8"), false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(p, false); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(p); string(data) != sample {
+		t.Errorf("Restore did not bring back the original:\n%s", data)
+	}
+	if _, err := os.Stat(BackupPath(p)); !os.IsNotExist(err) {
+		t.Errorf("Expected the backup to be removed")
+	}
+}
+
+func TestDryRunLeavesFile(t *testing.T) {
+	p := writeFile(t, sample)
+	if err := Update(p, servers("1.1.1.1"), false, true); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(p); string(data) != sample {
+		t.Errorf("dry run modified the file")
+	}
+}
@@ -7,3 +7,41 @@ var Presets = map[string][]string{
 	"quad9":      {"9.9.9.9:53", "149.112.112.112:53"},
 	"opendns":    {"208.67.222.222:53", "208.67.220.220:53"},
 }
+
+// Profile is a named DNS configuration
+type Profile struct {
+	Servers []string
+	Search  []string // search domains
+	Options []string // resolver options such as "ndots:5" or "rotate"
+}
+
+// Custom holds user-defined profiles; unlike presets they may carry
+// search domains and options. A custom profile shadows a preset.
+var Custom = map[string]Profile{}
+
+// Lookup finds a profile by name
+func Lookup(name string) (Profile, bool) {
+	if p, ok := Custom[name]; ok {
+		return p, true
+	}
+	if servers, ok := Presets[name]; ok {
+		return Profile{Servers: servers}, true
+	}
+	return Profile{}, false
+}
+
+// Names lists every profile name, presets and custom ones
+func Names() []string {
+	seen := map[string]bool{}
+	var out []string
+	for n := range Presets {
+		seen[n] = true
+		out = append(out, n)
+	}
+	for n := range Custom {
+		if !seen[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
@@ -79,3 +79,27 @@ func TestPresetOrder(t *testing.T) {
 		t.Errorf("Expected primary Google DNS to be first, got %v", google)
 	}
 }
+
+func TestLookupPrefersCustom(t *testing.T) {
+	orig := Custom
+	t.Cleanup(func() { Custom = orig })
+	Custom = map[string]Profile{
+		"cloudflare": {Servers: []string{"1.1.1.1"}, Search: []string{"corp.example"}},
+		"k8s":        {Servers: []string{"10.96.0.10"}, Options: []string{"ndots:5"}},
+	}
+
+	p, ok := Lookup("cloudflare")
+	if !ok || len(p.Search) != 1 || p.Search[0] != "corp.example" {
+		t.Errorf("Expected the custom cloudflare profile, got %+v", p)
+	}
+	p, ok = Lookup("google")
+	if !ok || len(p.Servers) != 2 || p.Search != nil {
+		t.Errorf("Expected the google preset, got %+v", p)
+	}
+	if _, ok := Lookup("missing"); ok {
+		t.Error("Expected missing profile to fail")
+	}
+	if n := len(Names()); n != len(Presets)+1 {
+		t.Errorf("Expected %d names, got %d", len(Presets)+1, n)
+	}
+}
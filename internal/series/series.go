@@ -0,0 +1,219 @@
+package series
+
+// Time series of benchmark rounds: an append-only JSONL file with one line
+// per query, written by benchmark --duration and summarized by stats.
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"dns-helper/internal/bench"
+)
+
+// DefaultPath is timeseries.jsonl in the dns-helper configuration directory
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dns-helper", "timeseries.jsonl")
+}
+
+// Record is one stored query; Round is the start of its round
+type Record struct {
+	Round time.Time `json:"round"`
+	bench.Sample
+}
+
+// Append adds the samples of one round to the store at path
+func Append(path string, round time.Time, samples []bench.Sample) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, s := range samples {
+		if err := enc.Encode(Record{Round: round.UTC(), Sample: s}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads the records of rounds started at or after since, oldest
+// first; a zero since reads everything
+func Load(path string, since time.Time) ([]Record, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no time series at %s (record one with benchmark --duration)", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []Record
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return out, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if !r.Round.Before(since) {
+			out = append(out, r)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Round.Before(out[j].Round) })
+	return out, sc.Err()
+}
+
+// add counts one record into a benchmark result
+func add(r *bench.Result, rec Record) {
+	r.Total++
+	if rec.Class == bench.ClassSuccess {
+		r.Successes++
+		r.Latencies = append(r.Latencies, rec.Latency)
+	}
+	r.Samples = append(r.Samples, rec.Sample)
+}
+
+// Profiles returns the profile names in records, sorted
+func Profiles(records []Record) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, r := range records {
+		if !seen[r.Profile] {
+			seen[r.Profile] = true
+			out = append(out, r.Profile)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Rounds counts the distinct rounds in records
+func Rounds(records []Record) int {
+	seen := map[time.Time]bool{}
+	for _, r := range records {
+		seen[r.Round] = true
+	}
+	return len(seen)
+}
+
+// ByProfile combines every query of each profile
+func ByProfile(records []Record) map[string]bench.Result {
+	out := map[string]bench.Result{}
+	for _, rec := range records {
+		r := out[rec.Profile]
+		add(&r, rec)
+		out[rec.Profile] = r
+	}
+	return out
+}
+
+// Hour holds the queries of one hour of the day, by profile
+type Hour struct {
+	Hour     int
+	Profiles map[string]bench.Result
+}
+
+// ByHour groups queries by the hour of day of their round in loc; hours
+// without data are left out
+func ByHour(records []Record, loc *time.Location) []Hour {
+	hours := map[int]map[string]bench.Result{}
+	for _, rec := range records {
+		h := rec.Round.In(loc).Hour()
+		if hours[h] == nil {
+			hours[h] = map[string]bench.Result{}
+		}
+		r := hours[h][rec.Profile]
+		add(&r, rec)
+		hours[h][rec.Profile] = r
+	}
+	var out []Hour
+	for h := 0; h < 24; h++ {
+		if hours[h] != nil {
+			out = append(out, Hour{Hour: h, Profiles: hours[h]})
+		}
+	}
+	return out
+}
+
+// Outage is a run of consecutive rounds in which a profile's success rate
+// stayed below the outage threshold
+type Outage struct {
+	Profile    string
+	Start, End time.Time      // first failing round, and the round that recovered or the last query
+	Rounds     int            // failing rounds
+	Recovered  bool           // a later round succeeded
+	Failures   map[string]int // failed queries by class
+}
+
+// Outages finds the outage windows of every profile, by profile and then
+// time. below is the success rate in percent under which a round fails.
+func Outages(records []Record, below float64) []Outage {
+	type round struct {
+		start, last time.Time
+		result      bench.Result
+	}
+	rounds := map[string][]*round{}
+	for _, rec := range records {
+		rs := rounds[rec.Profile]
+		if len(rs) == 0 || !rs[len(rs)-1].start.Equal(rec.Round) {
+			rs = append(rs, &round{start: rec.Round})
+			rounds[rec.Profile] = rs
+		}
+		cur := rs[len(rs)-1]
+		add(&cur.result, rec)
+		if rec.Time.After(cur.last) {
+			cur.last = rec.Time
+		}
+	}
+
+	var out []Outage
+	for _, profile := range Profiles(records) {
+		var cur *Outage
+		for _, r := range rounds[profile] {
+			failing := r.result.Total > 0 && 100*float64(r.result.Successes)/float64(r.result.Total) < below
+			if !failing {
+				if cur != nil {
+					cur.End, cur.Recovered = r.start, true
+					out = append(out, *cur)
+					cur = nil
+				}
+				continue
+			}
+			if cur == nil {
+				cur = &Outage{Profile: profile, Start: r.start, Failures: map[string]int{}}
+			}
+			cur.Rounds++
+			cur.End = r.last
+			for _, s := range r.result.Samples {
+				if s.Class != bench.ClassSuccess {
+					cur.Failures[s.Class]++
+				}
+			}
+		}
+		if cur != nil {
+			out = append(out, *cur)
+		}
+	}
+	return out
+}
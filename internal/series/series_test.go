@@ -0,0 +1,99 @@
+package series
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-helper/internal/bench"
+)
+
+var t0 = time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+
+// round makes the samples of one round: ok successes and failed timeouts
+func round(profile string, at time.Time, ok, failed int) []bench.Sample {
+	var out []bench.Sample
+	for i := 0; i < ok+failed; i++ {
+		s := bench.Sample{Time: at.Add(time.Duration(i) * time.Second), Profile: profile, Domain: "example.com", Class: bench.ClassSuccess, Latency: 10 * time.Millisecond}
+		if i >= ok {
+			s.Class, s.Latency = bench.ClassTimeout, 0
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestAppendLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "timeseries.jsonl")
+	for i := 0; i < 3; i++ {
+		at := t0.Add(time.Duration(i) * time.Hour)
+		if err := Append(path, at, round("cloudflare", at, 2, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, err := Load(path, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 9 || Rounds(all) != 3 || all[0].Latency != 10*time.Millisecond || all[2].Class != bench.ClassTimeout {
+		t.Fatalf("Unexpected records: %+v", all)
+	}
+	recent, _ := Load(path, t0.Add(time.Hour))
+	if Rounds(recent) != 2 {
+		t.Errorf("Expected the last two rounds, got %d", Rounds(recent))
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.jsonl"), time.Time{}); err == nil {
+		t.Error("Expected an error for a missing store")
+	}
+}
+
+func records(rounds ...[]bench.Sample) []Record {
+	var out []Record
+	for _, r := range rounds {
+		for _, s := range r {
+			out = append(out, Record{Round: r[0].Time, Sample: s})
+		}
+	}
+	return out
+}
+
+func TestByHour(t *testing.T) {
+	recs := records(
+		round("cloudflare", t0, 3, 0),
+		round("cloudflare", t0.Add(30*time.Minute), 1, 2),
+		round("cloudflare", t0.Add(5*time.Hour), 3, 0),
+	)
+	hours := ByHour(recs, time.UTC)
+	if len(hours) != 2 || hours[0].Hour != 2 || hours[1].Hour != 7 {
+		t.Fatalf("Unexpected hours: %+v", hours)
+	}
+	if r := hours[0].Profiles["cloudflare"]; r.Total != 6 || r.Successes != 4 {
+		t.Errorf("Hour 2 should combine both rounds, got %d/%d", r.Successes, r.Total)
+	}
+}
+
+func TestOutages(t *testing.T) {
+	step := 5 * time.Minute
+	recs := records(
+		round("cloudflare", t0, 3, 0),
+		round("quad9", t0, 3, 0),
+		round("cloudflare", t0.Add(step), 0, 3),
+		round("quad9", t0.Add(step), 3, 0),
+		round("cloudflare", t0.Add(2*step), 1, 2),
+		round("quad9", t0.Add(2*step), 2, 1),
+		round("cloudflare", t0.Add(3*step), 3, 0),
+		round("quad9", t0.Add(3*step), 0, 3),
+	)
+	got := Outages(recs, 50)
+	if len(got) != 2 {
+		t.Fatalf("Expected two outages, got %+v", got)
+	}
+	cf := got[0]
+	if cf.Profile != "cloudflare" || !cf.Start.Equal(t0.Add(step)) || !cf.End.Equal(t0.Add(3*step)) || cf.Rounds != 2 || !cf.Recovered || cf.Failures[bench.ClassTimeout] != 5 {
+		t.Errorf("Unexpected cloudflare outage: %+v", cf)
+	}
+	q9 := got[1]
+	if q9.Profile != "quad9" || q9.Recovered || q9.Rounds != 1 || !q9.End.Equal(t0.Add(3*step+2*time.Second)) {
+		t.Errorf("Unexpected quad9 outage, expected it ongoing until its last query: %+v", q9)
+	}
+}
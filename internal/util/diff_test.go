@@ -0,0 +1,20 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	if d := Diff("a", "b", "same\n", "same\n"); d != "" {
+		t.Errorf("Expected no diff for equal texts, got %q", d)
+	}
+	got := Diff("old", "new", "a\nb\nc\n", "a\nx\nc\nd\n")
+	want := "--- old\n+++ new\n a\n-b\n+x\n c\n+d\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	got = Diff("old", "new", "", "a\n")
+	if got != "--- old\n+++ new\n+a\n" {
+		t.Errorf("Unexpected diff from empty: %q", got)
+	}
+}
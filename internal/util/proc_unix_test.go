@@ -0,0 +1,64 @@
+//go:build !windows
+
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunTimeoutKillsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	start := time.Now()
+	// the background sleep is a grandchild holding stdout open
+	result := Run(200*time.Millisecond, "sh", "-c", `sleep 5 & echo $! > "$1"; wait`, "sh", pidFile)
+	if !errors.Is(result.Err, ErrTimeout) {
+		t.Errorf("Expected ErrTimeout, got %v", result.Err)
+	}
+	var ce *CommandError
+	if !errors.As(result.Err, &ce) || ce.ExitCode != -1 || ce.Argv[0] != "sh" {
+		t.Errorf("Expected a CommandError for a killed command, got %#v", result.Err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Run waited %s for the grandchild", d)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		if !running(pid) {
+			break
+		}
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("grandchild %d survived the timeout", pid)
+		}
+	}
+}
+
+// running reports whether pid exists and is not a zombie; the killed
+// grandchild is reparented and may wait a while to be reaped
+func running(pid int) bool {
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true // no procfs: only ESRCH tells
+	}
+	// the state follows the parenthesized command name
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
@@ -2,6 +2,9 @@ package util
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os/exec"
 	"strings"
 	"time"
@@ -10,36 +13,163 @@ import (
 type CmdResult struct {
 	Stdout string
 	Stderr string
-	Err    error
+	Err    error // a *CommandError
+}
+
+// ErrTimeout is wrapped by the CommandError of a command that ran longer
+// than its timeout and was killed
+var ErrTimeout = errors.New("timed out")
+
+// CommandError describes a command that could not start, exited non-zero,
+// timed out or was canceled
+type CommandError struct {
+	Argv     []string
+	ExitCode int // -1 when the command did not start or was killed
+	Stderr   string
+	Err      error // *exec.ExitError, ErrTimeout, context.Canceled or a start error
+}
+
+func (e *CommandError) Error() string {
+	msg := strings.Join(e.Argv, " ") + ": " + e.Err.Error()
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error { return e.Err }
+
+// baseCtx cancels every command when the CLI is interrupted
+var baseCtx = context.Background()
+
+// SetContext makes commands started from now on stop when ctx is done
+func SetContext(ctx context.Context) {
+	baseCtx = ctx
+}
+
+// Runner executes external commands. Platform code takes a Runner so tests
+// can substitute a fake and inspect the commands it would run.
+type Runner interface {
+	Run(timeout time.Duration, name string, args ...string) CmdResult
+	RunInput(timeout time.Duration, input string, name string, args ...string) CmdResult
+}
+
+// ExecRunner is the Runner that executes real commands
+type ExecRunner struct{}
+
+func (ExecRunner) Run(timeout time.Duration, name string, args ...string) CmdResult {
+	return Run(timeout, name, args...)
+}
+
+func (ExecRunner) RunInput(timeout time.Duration, input string, name string, args ...string) CmdResult {
+	return RunInput(timeout, input, name, args...)
 }
 
 func Run(timeout time.Duration, name string, args ...string) CmdResult {
-	cmd := exec.Command(name, args...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	return RunInput(timeout, "", name, args...)
+}
+
+// Invocation describes one command run through Run or RunInput
+type Invocation struct {
+	Argv     []string      `json:"argv"`
+	Stdin    string        `json:"stdin,omitempty"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration_ns"`
+	ExitCode int           `json:"exit_code"` // -1 when it did not start or was killed
+	Stdout   string        `json:"stdout,omitempty"`
+	Stderr   string        `json:"stderr,omitempty"`
+	Err      string        `json:"error,omitempty"`
+}
+
+// auditLimit caps the output kept in an Invocation
+const auditLimit = 4096
+
+// Audit, when set, receives every command run through Run or RunInput
+var Audit func(Invocation)
+
+func audit(argv []string, input string, start time.Time, res CmdResult) {
+	inv := Invocation{
+		Argv:     argv,
+		Stdin:    trimOutput(input),
+		Start:    start,
+		Duration: time.Since(start),
+		ExitCode: exitCode(res.Err),
+		Stdout:   trimOutput(res.Stdout),
+		Stderr:   trimOutput(res.Stderr),
+	}
+	var ce *CommandError
+	if errors.As(res.Err, &ce) {
+		inv.Err = ce.Err.Error() // argv and stderr are recorded already
+	} else if res.Err != nil {
+		inv.Err = res.Err.Error()
+	}
+	Audit(inv)
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ce *CommandError
+	if errors.As(err, &ce) {
+		return ce.ExitCode
+	}
+	return -1
+}
+
+func trimOutput(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > auditLimit {
+		return s[:auditLimit] + "... (truncated)"
+	}
+	return s
+}
+
+// RunInput is Run with input written to the command's stdin
+func RunInput(timeout time.Duration, input string, name string, args ...string) CmdResult {
+	if Audit != nil {
+		start := time.Now()
+		res := runInput(timeout, input, name, args...)
+		audit(append([]string{name}, args...), input, start, res)
+		return res
+	}
+	return runInput(timeout, input, name, args...)
+}
+
+func runInput(timeout time.Duration, input string, name string, args ...string) CmdResult {
 	if timeout <= 0 {
 		timeout = 10 * time.Second
 	}
-	err := runWithTimeout(cmd, timeout)
-	return CmdResult{
+	parent := baseCtx
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	// grandchildren may keep the output pipes open after the kill
+	cmd.WaitDelay = time.Second
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	res := CmdResult{
 		Stdout: strings.TrimSpace(stdout.String()),
 		Stderr: strings.TrimSpace(stderr.String()),
-		Err:    err,
-	}
-}
-
-func runWithTimeout(cmd *exec.Cmd, timeout time.Duration) error {
-	if err := cmd.Start(); err != nil {
-		return err
 	}
-	done := make(chan error, 1)
-	go func() { done <- cmd.Wait() }()
-	select {
-	case err := <-done:
-		return err
-	case <-time.After(timeout):
-		_ = cmd.Process.Kill()
-		return exec.ErrNotFound // generic timeout err
+	if err != nil {
+		ce := &CommandError{Argv: append([]string{name}, args...), ExitCode: -1, Stderr: res.Stderr, Err: err}
+		var exitErr *exec.ExitError
+		switch {
+		case parent.Err() != nil:
+			ce.Err = parent.Err()
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			ce.Err = fmt.Errorf("%w after %s", ErrTimeout, timeout)
+		case errors.As(err, &exitErr):
+			ce.ExitCode = exitErr.ExitCode()
+		}
+		res.Err = ce
 	}
+	return res
 }
@@ -1,6 +1,8 @@
 package util
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -104,3 +106,48 @@ func TestRunWithSpecialCharacters(t *testing.T) {
 		t.Errorf("Expected stdout '%s', got '%s'", expected, result.Stdout)
 	}
 }
+
+func TestRunAudit(t *testing.T) {
+	var got []Invocation
+	Audit = func(inv Invocation) { got = append(got, inv) }
+	defer func() { Audit = nil }()
+
+	RunInput(5*time.Second, "input", "sh", "-c", "cat; echo oops >&2; exit 3")
+	Run(1*time.Second, "nonexistentcommand")
+
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 invocations, got %d", len(got))
+	}
+	inv := got[0]
+	if inv.Argv[0] != "sh" || inv.Stdin != "input" || inv.Stdout != "input" || inv.Stderr != "oops" || inv.ExitCode != 3 || inv.Duration <= 0 {
+		t.Errorf("Unexpected invocation: %+v", inv)
+	}
+	if got[1].ExitCode != -1 || got[1].Err == "" {
+		t.Errorf("A command that did not start must have exit code -1, got %+v", got[1])
+	}
+}
+
+func TestRunCommandError(t *testing.T) {
+	result := Run(5*time.Second, "sh", "-c", "echo denied >&2; exit 4")
+	var ce *CommandError
+	if !errors.As(result.Err, &ce) || ce.ExitCode != 4 || ce.Stderr != "denied" {
+		t.Fatalf("Unexpected error: %#v", result.Err)
+	}
+	if result.Err.Error() != "sh -c echo denied >&2; exit 4: exit status 4: denied" {
+		t.Errorf("Unexpected message: %s", result.Err)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	SetContext(ctx)
+	defer SetContext(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	result := Run(5*time.Second, "sleep", "5")
+	if !errors.Is(result.Err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", result.Err)
+	}
+}